@@ -1,6 +1,6 @@
 module github.com/mhrabovcin/cache
 
-go 1.12
+go 1.21
 
 require (
 	docker.io/go-docker v1.0.0
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultBatchWindow is the time a batch of missing keys waits for more keys
+// before it's loaded
+const DefaultBatchWindow = 2 * time.Millisecond
+
+// BatchLoadFunc loads multiple keys for a read-through source in a single
+// call. Keys missing in the returned map are treated as not found, an error
+// fails the load of all keys in the batch.
+type BatchLoadFunc func(ctx context.Context, keys []string) (map[string]string, error)
+
+// batchLoader collects keys missed by concurrent Get calls and loads them
+// with a single BatchLoadFunc call
+type batchLoader struct {
+	load    BatchLoadFunc
+	window  time.Duration
+	maxSize int
+
+	lock    sync.Mutex
+	pending *batch
+}
+
+// batch is a set of keys waiting to be loaded together
+type batch struct {
+	keys  []string
+	calls []*loadCall
+	timer *time.Timer
+}
+
+// add adds a key to the pending batch. The batch is loaded when the window
+// expires or when it's full, in which case it's loaded by the caller.
+func (b *batchLoader) add(s *source, l *lazyStore, key string, c *loadCall) {
+	b.lock.Lock()
+	p := b.pending
+	if p == nil {
+		p = &batch{}
+		p.timer = time.AfterFunc(b.window, func() {
+			if b.take(p) {
+				b.run(s, l, p)
+			}
+		})
+		b.pending = p
+	}
+	p.keys = append(p.keys, key)
+	p.calls = append(p.calls, c)
+	full := b.maxSize > 0 && len(p.keys) >= b.maxSize
+	if full {
+		b.pending = nil
+	}
+	b.lock.Unlock()
+
+	if full {
+		p.timer.Stop()
+		b.run(s, l, p)
+	}
+}
+
+// take removes p from pending, it returns false if p was already loaded
+// because it was full
+func (b *batchLoader) take(p *batch) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.pending != p {
+		return false
+	}
+	b.pending = nil
+	return true
+}
+
+// run loads the batch and stores the results
+func (b *batchLoader) run(s *source, l *lazyStore, p *batch) {
+	ctx, cancel := s.fetchContext()
+	defer cancel()
+
+	data, err := b.load(ctx, p.keys)
+	if err != nil {
+		s.logger.Warn("failed to load keys", "source", s.name, "keys", len(p.keys), "error", err)
+	}
+
+	results := make([]loadResult, len(p.keys))
+	for i, key := range p.keys {
+		results[i] = loadResult{key: key, call: p.calls[i], err: err}
+		if err != nil {
+			continue
+		}
+		if value, ok := data[key]; ok {
+			results[i].value = value
+		} else {
+			results[i].err = ErrKeyNotFound
+		}
+	}
+
+	l.complete(results)
+}
@@ -0,0 +1,143 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+// recordingBatchLoadFunc returns values for keys prefixed with "key" and
+// records requested batches
+func recordingBatchLoadFunc(lock *sync.Mutex, batches *[]string) cache.BatchLoadFunc {
+	return func(ctx context.Context, keys []string) (map[string]string, error) {
+		sorted := append([]string(nil), keys...)
+		sort.Strings(sorted)
+
+		lock.Lock()
+		*batches = append(*batches, strings.Join(sorted, ","))
+		lock.Unlock()
+
+		data := map[string]string{}
+		for _, k := range keys {
+			if strings.HasPrefix(k, "key") {
+				data[k] = "loaded-" + k
+			}
+		}
+		return data, nil
+	}
+}
+
+func getConcurrently(s cache.Source, keys ...string) map[string]error {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	errs := map[string]error{}
+
+	for _, k := range keys {
+		wg.Add(1)
+		go func(k string) {
+			defer wg.Done()
+			item, err := s.Get(k)
+			if err == nil && item.Value() != "loaded-"+k {
+				err = fmt.Errorf("unexpected value %q", item.Value())
+			}
+			lock.Lock()
+			errs[k] = err
+			lock.Unlock()
+		}(k)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+func TestBatchLoad(t *testing.T) {
+	var lock sync.Mutex
+	var batches []string
+
+	s := cache.NewSource(
+		"test",
+		cache.WithBatchLoadFunc(recordingBatchLoadFunc(&lock, &batches), time.Hour),
+		cache.WithBatchWindow(50*time.Millisecond, 0),
+	)
+	defer s.Stop()
+
+	errs := getConcurrently(s, "key1", "key2", "key3", "missing", "key1")
+	for k, err := range errs {
+		if k == "missing" {
+			if err != cache.ErrKeyNotFound {
+				t.Fatal("key missing in batch result should be not found, got:", err)
+			}
+		} else if err != nil {
+			t.Fatal(k, err)
+		}
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(batches) != 1 || batches[0] != "key1,key2,key3,missing" {
+		t.Fatal("keys should be loaded in a single batch, got:", batches)
+	}
+
+	if s.Status().LoadedKeys != 3 {
+		t.Fatal("loaded keys should be cached")
+	}
+}
+
+func TestBatchLoadMaxSize(t *testing.T) {
+	var lock sync.Mutex
+	var batches []string
+
+	s := cache.NewSource(
+		"test",
+		cache.WithBatchLoadFunc(recordingBatchLoadFunc(&lock, &batches), time.Hour),
+		cache.WithBatchWindow(time.Hour, 2),
+	)
+	defer s.Stop()
+
+	start := time.Now()
+	errs := getConcurrently(s, "key1", "key2", "key3", "key4")
+	for k, err := range errs {
+		if err != nil {
+			t.Fatal(k, err)
+		}
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("full batches should be loaded without waiting for the window")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(batches) != 2 {
+		t.Fatal("keys should be loaded in batches of two, got:", batches)
+	}
+}
+
+func TestBatchLoadError(t *testing.T) {
+	loadFunc := func(ctx context.Context, keys []string) (map[string]string, error) {
+		return nil, fmt.Errorf("error")
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithBatchLoadFunc(loadFunc, time.Hour),
+		cache.WithLogger(cache.NopLogger()),
+	)
+	defer s.Stop()
+
+	errs := getConcurrently(s, "key1", "key2")
+	for k, err := range errs {
+		if err == nil || err.Error() != "error" {
+			t.Fatal("batch error should be returned for", k, err)
+		}
+	}
+
+	if s.Status().LoadedKeys != 0 {
+		t.Fatal("failed keys shouldn't be cached")
+	}
+}
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"context"
+	"time"
+	"unsafe"
+)
+
+// BytesFetchFunc is a fetch function returning binary values, e.g. protobuf
+// messages or compressed blobs. The returned slices are owned by the source
+// and must not be modified after the function returns.
+type BytesFetchFunc func(ctx context.Context) (map[string][]byte, error)
+
+// WrapBytesFetchFunc adapts a BytesFetchFunc to FetchFunc. Values are
+// converted to strings without copying.
+func WrapBytesFetchFunc(f BytesFetchFunc) FetchFunc {
+	return func(ctx context.Context) (map[string]string, error) {
+		raw, err := f(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		data := make(map[string]string, len(raw))
+		for k, v := range raw {
+			data[k] = bytesToString(v)
+		}
+		return data, nil
+	}
+}
+
+// WithBytesFetchFunc sets a refresh function returning binary values and
+// frequency in which should be function invoked.
+func WithBytesFetchFunc(f BytesFetchFunc, freq time.Duration) Option {
+	return WithFetchFunc(WrapBytesFetchFunc(f), freq)
+}
+
+// bytesToString converts b to string without copying. The caller must not
+// modify b afterwards.
+func bytesToString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+// stringToBytes returns a read-only view of s without copying
+func stringToBytes(s string) []byte {
+	if s == "" {
+		return nil
+	}
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
+
+// ValueBytes returns the value without copying. The returned slice must not
+// be modified.
+func (i *item) ValueBytes() []byte {
+	return stringToBytes(i.value)
+}
+
+// NewItemBytes creates a cache item with binary value and metadata. The
+// value isn't copied and must not be modified afterwards.
+func NewItemBytes(
+	value []byte,
+	lastRefreshed time.Time,
+	nextRefresh time.Time,
+) Item {
+	return NewItem(bytesToString(value), lastRefreshed, nextRefresh)
+}
@@ -0,0 +1,53 @@
+package cache_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+func TestBytesFetchFunc(t *testing.T) {
+	blob := []byte{0x00, 0xff, 0x10, 0x80}
+	fetchFunc := func(ctx context.Context) (map[string][]byte, error) {
+		return map[string][]byte{"blob": blob, "empty": nil}, nil
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithBytesFetchFunc(fetchFunc, time.Hour),
+	)
+	defer s.Stop()
+
+	if err := s.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := s.Get("blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(item.ValueBytes(), blob) {
+		t.Fatal("binary value should be preserved")
+	}
+	if &item.ValueBytes()[0] != &blob[0] {
+		t.Fatal("binary value shouldn't be copied")
+	}
+
+	item, err = s.Get("empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(item.ValueBytes()) != 0 || item.Value() != "" {
+		t.Fatal("empty value should be empty")
+	}
+}
+
+func TestNewItemBytes(t *testing.T) {
+	item := cache.NewItemBytes([]byte("value"), time.Now(), cache.Never)
+	if item.Value() != "value" || string(item.ValueBytes()) != "value" {
+		t.Fatal("item should expose value as string and bytes")
+	}
+}
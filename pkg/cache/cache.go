@@ -1,7 +1,11 @@
 package cache
 
 import (
+	"context"
 	"errors"
+	"sort"
+	"sync"
+	"time"
 )
 
 var (
@@ -10,6 +14,23 @@ var (
 
 	// ErrKeyNotFound indicates that key wasn't found in the data source
 	ErrKeyNotFound = errors.New("Key was not found")
+
+	// ErrNotReady is returned by a source that hasn't loaded its data yet
+	ErrNotReady = errors.New("Source is not ready")
+
+	// ErrStopped is returned when refreshing a source that was stopped
+	ErrStopped = errors.New("Source is stopped")
+
+	// ErrDataTooStale is returned by a source whose data is older than the
+	// maximum staleness
+	ErrDataTooStale = errors.New("Source data is too stale")
+
+	// ErrDuplicateSource is returned when adding a source with a name that
+	// is already registered in cache
+	ErrDuplicateSource = errors.New("Source already exists")
+
+	// ErrClosed is returned by a cache after Close was called
+	ErrClosed = errors.New("Cache is closed")
 )
 
 // Cache represents a global cache object that can be used to access a source
@@ -17,32 +38,155 @@ var (
 type Cache interface {
 	Source(source string) (Source, error)
 	Get(source string, key string) (value Item, err error)
+
+	// Typed getters return def if the source or the key doesn't exist. Def
+	// is also returned along with any other error.
+	GetString(source, key string, def string) (string, error)
+	GetInt(source, key string, def int) (int, error)
+	GetFloat64(source, key string, def float64) (float64, error)
+	GetBool(source, key string, def bool) (bool, error)
+	GetDuration(source, key string, def time.Duration) (time.Duration, error)
+
+	// Status returns refresh status of all sources that report it, keyed by
+	// source name
+	Status() map[string]Status
+
+	// RefreshAll refreshes all sources that support manual refresh
+	// concurrently and returns errors of failed refreshes
+	RefreshAll(ctx context.Context) error
+
+	// WaitReady blocks until named sources, or all sources if no names are
+	// provided, have data to serve
+	WaitReady(ctx context.Context, names ...string) error
+
+	// Subscribe returns a subscription receiving diffs from all sources that
+	// publish changes
+	Subscribe(buffer int) *Subscription
+
+	// OnChange calls f with diffs from all sources that publish changes. The
+	// returned function cancels the subscription.
+	OnChange(f func(Diff)) func()
+
+	// Add registers a source. It returns ErrDuplicateSource if a source with
+	// the same name is already registered.
+	Add(source Source) error
+
+	// Remove unregisters a source by name and stops it if it's a
+	// StoppableSource. It returns ErrSourceNotFound for unknown names.
+	Remove(name string) error
+
+	// Replace registers a source in place of a source with the same name,
+	// which is stopped if it's a StoppableSource. Gets never observe the
+	// name missing. The source is added if the name isn't registered.
+	Replace(source Source) error
+
+	// Sources returns sorted names of registered sources
+	Sources() []string
+
+	// Close unregisters and stops all sources and closes subscriptions.
+	// Afterwards methods returning an error, including Close, return
+	// ErrClosed. Status and Sources return no sources, Subscribe returns a
+	// closed subscription and OnChange never calls its function.
+	Close(ctx context.Context) error
+}
+
+// CacheOption is a function that sets an option for a cache
+type CacheOption func(*CacheOptions)
+
+// CacheOptions for configuring a cache. The options shouldn't be used
+// directly but through WithCache... functions.
+type CacheOptions struct {
+	Logger    Logger
+	Scheduler *Scheduler
+}
+
+// WithCacheLogger provides a custom logger for the cache. Sources are
+// configured with own logger using WithLogger.
+func WithCacheLogger(l Logger) CacheOption {
+	return func(o *CacheOptions) {
+		o.Logger = l
+	}
 }
 
-// New creates a new global cache instance with provided sources
+// WithCacheScheduler makes the cache own a scheduler shared by its sources.
+// The scheduler is stopped by Close after all sources stopped.
+func WithCacheScheduler(s *Scheduler) CacheOption {
+	return func(o *CacheOptions) {
+		o.Scheduler = s
+	}
+}
+
+// New creates a new global cache instance with provided sources. It panics
+// on duplicate source names, use Add to register sources with error checking.
 func New(sources ...Source) Cache {
-	s := make(map[string]Source)
+	return NewWithOptions(sources)
+}
+
+// NewWithOptions creates a new global cache instance with provided sources
+// and options
+func NewWithOptions(sources []Source, opts ...CacheOption) Cache {
+	o := &CacheOptions{
+		Logger: SlogLogger(nil),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	c := &cacheImpl{
+		sources:   map[string]Source{},
+		unlisten:  map[string]func(){},
+		logger:    o.Logger,
+		scheduler: o.Scheduler,
+	}
 
 	for _, source := range sources {
-		if _, ok := s[source.Name()]; ok {
+		if err := c.Add(source); err != nil {
 			panic("duplicate source name")
 		}
-		s[source.Name()] = source
 	}
 
-	return &cacheImpl{
-		sources: s,
-	}
+	return c
+}
+
+// changeSource is implemented by sources that publish data changes
+type changeSource interface {
+	listen(f func(Diff)) func()
 }
 
 type cacheImpl struct {
-	sources map[string]Source
+	notifier
+
+	// sources and functions forwarding their changes, guarded by lock
+	lock     sync.RWMutex
+	sources  map[string]Source
+	unlisten map[string]func()
+	closed   bool
+
+	logger    Logger
+	scheduler *Scheduler
+}
+
+// isClosed returns true once Close was called
+func (c *cacheImpl) isClosed() bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.closed
 }
 
 func (c *cacheImpl) Source(source string) (Source, error) {
+	c.lock.RLock()
 	s, ok := c.sources[source]
+	closed := c.closed
+	c.lock.RUnlock()
+
+	if closed {
+		return nil, ErrClosed
+	}
 
 	if !ok {
+		c.logger.Debug("source not found", "source", source)
 		return nil, ErrSourceNotFound
 	}
 
@@ -60,4 +204,139 @@ func (c *cacheImpl) Get(source string, key string) (value Item, err error) {
 	return s.Get(key)
 }
 
+// Add registers a source
+func (c *cacheImpl) Add(source Source) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+
+	name := source.Name()
+	if _, ok := c.sources[name]; ok {
+		return ErrDuplicateSource
+	}
+
+	c.register(source)
+	c.logger.Debug("source added", "source", name)
+	return nil
+}
+
+// Remove unregisters and stops a source
+func (c *cacheImpl) Remove(name string) error {
+	c.lock.Lock()
+	s, ok := c.sources[name]
+	if ok {
+		c.unregister(name)
+	}
+	closed := c.closed
+	c.lock.Unlock()
+
+	if closed {
+		return ErrClosed
+	}
+	if !ok {
+		return ErrSourceNotFound
+	}
+
+	c.logger.Debug("source removed", "source", name)
+	stopSource(s)
+	return nil
+}
+
+// Replace swaps a source with the same name and stops the replaced one
+func (c *cacheImpl) Replace(source Source) error {
+	name := source.Name()
+
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return ErrClosed
+	}
+	old, ok := c.sources[name]
+	if ok {
+		if old == source {
+			c.lock.Unlock()
+			return nil
+		}
+		c.unregister(name)
+	}
+	c.register(source)
+	c.lock.Unlock()
+
+	c.logger.Debug("source replaced", "source", name)
+	if ok {
+		stopSource(old)
+	}
+	return nil
+}
+
+// Sources returns sorted names of registered sources
+func (c *cacheImpl) Sources() []string {
+	c.lock.RLock()
+	names := make([]string, 0, len(c.sources))
+	for name := range c.sources {
+		names = append(names, name)
+	}
+	c.lock.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
+// register adds a source and forwards its changes, it must be called with
+// lock held
+func (c *cacheImpl) register(source Source) {
+	name := source.Name()
+	c.sources[name] = source
+	if cs, ok := source.(changeSource); ok {
+		c.unlisten[name] = cs.listen(c.publish)
+	}
+}
+
+// unregister removes a source and stops forwarding its changes, it must be
+// called with lock held
+func (c *cacheImpl) unregister(name string) {
+	if unlisten, ok := c.unlisten[name]; ok {
+		unlisten()
+		delete(c.unlisten, name)
+	}
+	delete(c.sources, name)
+}
+
+// all returns a copy of registered sources, so they can be iterated without
+// holding the lock
+func (c *cacheImpl) all() map[string]Source {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	sources := make(map[string]Source, len(c.sources))
+	for name, s := range c.sources {
+		sources[name] = s
+	}
+	return sources
+}
+
+// stopSource stops sources that can be stopped
+func stopSource(s Source) {
+	if ss, ok := s.(interface{ Stop() }); ok {
+		ss.Stop()
+	}
+}
+
+// Status returns refresh status of all sources that report it
+func (c *cacheImpl) Status() map[string]Status {
+	sources := c.all()
+	status := make(map[string]Status, len(sources))
+
+	for name, s := range sources {
+		if ss, ok := s.(statusSource); ok {
+			status[name] = ss.Status()
+		}
+	}
+
+	return status
+}
+
 var _ Cache = (*cacheImpl)(nil)
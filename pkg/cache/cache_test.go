@@ -1,6 +1,8 @@
 package cache_test
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -86,3 +88,215 @@ func TestCacheDupliciteSourceNamesShouldPanic(t *testing.T) {
 
 	cache.New(s1, s2)
 }
+
+func TestCacheStatus(t *testing.T) {
+	lastRefresh := time.Now().Add(-1 * time.Minute)
+	s1 := cache.NewStaticSource(
+		"s1",
+		map[string]string{"key": "value"},
+		lastRefresh,
+	)
+	s2 := cache.NewStaticSource(
+		"s2",
+		map[string]string{"key": "value", "key2": "value2"},
+		lastRefresh,
+	)
+
+	status := cache.New(s1, s2).Status()
+	if len(status) != 2 {
+		t.Fatal("status should be reported for all sources")
+	}
+
+	if status["s1"].Keys != 1 || status["s2"].Keys != 2 {
+		t.Fatal("wrong number of keys reported")
+	}
+
+	if !status["s1"].LastRefreshed.Equal(lastRefresh) {
+		t.Fatal("wrong last refresh time reported")
+	}
+}
+
+func TestCacheTypedGetters(t *testing.T) {
+	s := cache.NewStaticSource(
+		"config",
+		map[string]string{
+			"name":    "test",
+			"limit":   "10",
+			"ratio":   "0.5",
+			"enabled": "true",
+			"timeout": "5s",
+			"invalid": "abc",
+		},
+		time.Now(),
+	)
+	c := cache.New(s)
+
+	if v, err := c.GetString("config", "name", "default"); err != nil || v != "test" {
+		t.Fatal("wrong string value", v, err)
+	}
+	if v, err := c.GetInt("config", "limit", 1); err != nil || v != 10 {
+		t.Fatal("wrong int value", v, err)
+	}
+	if v, err := c.GetFloat64("config", "ratio", 1); err != nil || v != 0.5 {
+		t.Fatal("wrong float value", v, err)
+	}
+	if v, err := c.GetBool("config", "enabled", false); err != nil || !v {
+		t.Fatal("wrong bool value", v, err)
+	}
+	if v, err := c.GetDuration("config", "timeout", time.Second); err != nil || v != 5*time.Second {
+		t.Fatal("wrong duration value", v, err)
+	}
+
+	if v, err := c.GetInt("config", "missing", 7); err != nil || v != 7 {
+		t.Fatal("missing key should return default", v, err)
+	}
+	if v, err := c.GetInt("missing", "limit", 7); err != nil || v != 7 {
+		t.Fatal("missing source should return default", v, err)
+	}
+	if v, err := c.GetInt("config", "invalid", 7); !errors.Is(err, cache.ErrInvalidValue) || v != 7 {
+		t.Fatal("invalid value should return default with error", v, err)
+	}
+}
+
+func TestCacheAddRemove(t *testing.T) {
+	c := cache.New()
+
+	fetchFunc, _ := versionedFetchFunc(map[string]string{"key": "1"})
+	s1 := cache.NewSource("s1", cache.WithFetchFunc(fetchFunc, time.Hour))
+	s1.WaitReady(context.Background())
+	if err := c.Add(s1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Add(cache.NewStaticSource("s1", nil, time.Now())); err != cache.ErrDuplicateSource {
+		t.Fatal("adding duplicate source should fail, got:", err)
+	}
+	c.Add(cache.NewStaticSource("s0", nil, time.Now()))
+
+	if names := c.Sources(); len(names) != 2 || names[0] != "s0" || names[1] != "s1" {
+		t.Fatal("unexpected sources:", names)
+	}
+
+	item, err := c.Get("s1", "key")
+	if err != nil || item.Value() != "1" {
+		t.Fatal("added source should be served")
+	}
+
+	if err := c.Remove("s1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Remove("s1"); err != cache.ErrSourceNotFound {
+		t.Fatal("removing unknown source should fail, got:", err)
+	}
+	if _, err := c.Get("s1", "key"); err != cache.ErrSourceNotFound {
+		t.Fatal("removed source shouldn't be served")
+	}
+	if err := s1.Refresh(context.Background()); err != cache.ErrStopped {
+		t.Fatal("removed source should be stopped")
+	}
+}
+
+func TestCacheReplace(t *testing.T) {
+	// Sources start with default data, so no initial fetch publishes a diff
+	// concurrently with the test
+	fetch1, _ := versionedFetchFunc(map[string]string{"key": "old"})
+	s1 := cache.NewSource(
+		"test",
+		cache.WithDefaultData(map[string]string{"key": "old"}),
+		cache.WithFetchFunc(fetch1, time.Hour),
+	)
+	c := cache.New(s1)
+
+	fetchFunc, _ := versionedFetchFunc(map[string]string{"key": "new-2"})
+	s2 := cache.NewSource(
+		"test",
+		cache.WithDefaultData(map[string]string{"key": "new"}),
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+	)
+	defer s2.Stop()
+
+	diffs := c.Subscribe(10)
+	defer diffs.Close()
+
+	if err := c.Replace(s2); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := c.Get("test", "key")
+	if err != nil || item.Value() != "new" {
+		t.Fatal("replacing source should be served")
+	}
+	if err := s1.Refresh(context.Background()); err != cache.ErrStopped {
+		t.Fatal("replaced source should be stopped")
+	}
+
+	if err := s2.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case d := <-diffs.C():
+		if d.Modified["key"].New != "new-2" {
+			t.Fatal("unexpected diff:", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("changes of replacing source should be forwarded")
+	}
+
+	if err := c.Replace(cache.NewStaticSource("other", nil, time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Sources()) != 2 {
+		t.Fatal("replacing unknown source should add it")
+	}
+}
+
+func TestCacheAddForwardsChanges(t *testing.T) {
+	// Without default data the diff of the initial fetch could be forwarded
+	fetchFunc, _ := versionedFetchFunc(map[string]string{"key": "2"})
+	s := cache.NewSource(
+		"test",
+		cache.WithDefaultData(map[string]string{"key": "1"}),
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+	)
+	defer s.Stop()
+
+	c := cache.New()
+	diffs := c.Subscribe(10)
+	defer diffs.Close()
+
+	c.Add(s)
+	if err := s.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case d := <-diffs.C():
+		if d.Source != "test" || d.Modified["key"].New != "2" {
+			t.Fatal("unexpected diff:", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("changes of added source should be forwarded")
+	}
+}
+
+func TestCacheConcurrentReplace(t *testing.T) {
+	c := cache.New(cache.NewStaticSource("test", map[string]string{"key": "0"}, time.Now()))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			c.Replace(cache.NewStaticSource("test", map[string]string{"key": "1"}, time.Now()))
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if _, err := c.Get("test", "key"); err != nil {
+			t.Fatal("replaced source should always be served, got:", err)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSubscriptionBuffer is a number of diffs buffered for subscriptions
+// created by OnChange
+const DefaultSubscriptionBuffer = 16
+
+// ValueChange holds previous and current value of a modified key
+type ValueChange struct {
+	Old string
+	New string
+}
+
+// Diff describes changes of source data made by a single refresh
+type Diff struct {
+	// Source is the name of the refreshed source
+	Source string
+
+	// LastRefreshed is the time of the refresh that produced the diff
+	LastRefreshed time.Time
+
+	// Added keys with their new values
+	Added map[string]string
+
+	// Removed keys with their last values
+	Removed map[string]string
+
+	// Modified keys with old and new values
+	Modified map[string]ValueChange
+}
+
+// Empty returns true if the refresh didn't change any key
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+func diffData(old, new map[string]string) Diff {
+	d := Diff{
+		Added:    map[string]string{},
+		Removed:  map[string]string{},
+		Modified: map[string]ValueChange{},
+	}
+
+	for k, v := range new {
+		oldValue, ok := old[k]
+		if !ok {
+			d.Added[k] = v
+		} else if oldValue != v {
+			d.Modified[k] = ValueChange{Old: oldValue, New: v}
+		}
+	}
+
+	for k, v := range old {
+		if _, ok := new[k]; !ok {
+			d.Removed[k] = v
+		}
+	}
+
+	return d
+}
+
+// Subscription receives diffs published after refreshes that changed data.
+// Delivery never blocks the refresh, if the buffer is full the diff is
+// dropped and counted.
+type Subscription struct {
+	ch      chan Diff
+	dropped uint64
+
+	n    *notifier
+	once sync.Once
+}
+
+// C returns a channel of diffs. The channel is closed when the subscription
+// or the source is closed.
+func (s *Subscription) C() <-chan Diff {
+	return s.ch
+}
+
+// Dropped returns the number of diffs that weren't delivered because the
+// subscription buffer was full
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close stops the delivery and closes the channel
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.n.remove(s)
+		close(s.ch)
+	})
+}
+
+func (s *Subscription) deliver(d Diff) {
+	select {
+	case s.ch <- d:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+// notifier keeps subscriptions and publishes diffs to them. It's embedded
+// into sources and cache to provide Subscribe and OnChange methods.
+type notifier struct {
+	subsLock  sync.Mutex
+	subs      map[*Subscription]struct{}
+	listeners map[*func(Diff)]struct{}
+	closed    bool
+}
+
+// Subscribe creates a subscription with provided buffer size
+func (n *notifier) Subscribe(buffer int) *Subscription {
+	s := &Subscription{
+		ch: make(chan Diff, buffer),
+		n:  n,
+	}
+
+	n.subsLock.Lock()
+	defer n.subsLock.Unlock()
+
+	if n.closed {
+		close(s.ch)
+		s.once.Do(func() {})
+		return s
+	}
+
+	if n.subs == nil {
+		n.subs = map[*Subscription]struct{}{}
+	}
+	n.subs[s] = struct{}{}
+	return s
+}
+
+// OnChange calls f with every published diff from a separate goroutine, so
+// a slow callback doesn't stall the refresh. The returned function cancels
+// the subscription.
+func (n *notifier) OnChange(f func(Diff)) func() {
+	s := n.Subscribe(DefaultSubscriptionBuffer)
+	go func() {
+		for d := range s.C() {
+			f(d)
+		}
+	}()
+	return s.Close
+}
+
+// listen registers f to be called synchronously on publish. It's used to
+// forward diffs between notifiers and f must not block.
+func (n *notifier) listen(f func(Diff)) func() {
+	n.subsLock.Lock()
+	defer n.subsLock.Unlock()
+
+	if n.listeners == nil {
+		n.listeners = map[*func(Diff)]struct{}{}
+	}
+	n.listeners[&f] = struct{}{}
+
+	return func() {
+		n.subsLock.Lock()
+		defer n.subsLock.Unlock()
+		delete(n.listeners, &f)
+	}
+}
+
+// hasSubscribers is used to skip computing diffs nobody would receive
+func (n *notifier) hasSubscribers() bool {
+	n.subsLock.Lock()
+	defer n.subsLock.Unlock()
+
+	return len(n.subs) > 0 || len(n.listeners) > 0
+}
+
+func (n *notifier) publish(d Diff) {
+	n.subsLock.Lock()
+	defer n.subsLock.Unlock()
+
+	for s := range n.subs {
+		s.deliver(d)
+	}
+	for f := range n.listeners {
+		(*f)(d)
+	}
+}
+
+func (n *notifier) remove(s *Subscription) {
+	n.subsLock.Lock()
+	defer n.subsLock.Unlock()
+
+	delete(n.subs, s)
+}
+
+// close closes all subscriptions. Subscriptions created after close are
+// returned already closed.
+func (n *notifier) close() {
+	n.subsLock.Lock()
+	subs := n.subs
+	n.subs = nil
+	n.listeners = nil
+	n.closed = true
+	n.subsLock.Unlock()
+
+	for s := range subs {
+		s.once.Do(func() {
+			close(s.ch)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package cache_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+func versionedFetchFunc(versions ...map[string]string) (cache.FetchFunc, func()) {
+	var version int32
+	return func(ctx context.Context) (map[string]string, error) {
+			v := atomic.LoadInt32(&version)
+			if int(v) >= len(versions) {
+				v = int32(len(versions) - 1)
+			}
+			return versions[v], nil
+		}, func() {
+			atomic.AddInt32(&version, 1)
+		}
+}
+
+func TestSourceSubscribe(t *testing.T) {
+	fetchFunc, next := versionedFetchFunc(
+		map[string]string{"same": "1", "modified": "old", "removed": "x"},
+		map[string]string{"same": "1", "modified": "new", "added": "y"},
+	)
+
+	s := cache.NewSource(
+		"test",
+		cache.WithDefaultData(map[string]string{"same": "1", "modified": "old", "removed": "x"}),
+		cache.WithFetchFunc(fetchFunc, 10*time.Millisecond),
+	)
+	defer s.Stop()
+
+	sub := s.Subscribe(1)
+	defer sub.Close()
+
+	next()
+
+	var d cache.Diff
+	select {
+	case d = <-sub.C():
+	case <-time.After(time.Second):
+		t.Fatal("diff should be delivered after refresh")
+	}
+
+	if d.Source != "test" || d.LastRefreshed.IsZero() {
+		t.Fatal("diff should include source and refresh time")
+	}
+	if len(d.Added) != 1 || d.Added["added"] != "y" {
+		t.Fatal("wrong added keys:", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed["removed"] != "x" {
+		t.Fatal("wrong removed keys:", d.Removed)
+	}
+	if len(d.Modified) != 1 || d.Modified["modified"] != (cache.ValueChange{Old: "old", New: "new"}) {
+		t.Fatal("wrong modified keys:", d.Modified)
+	}
+
+	// Unchanged refreshes don't publish diffs
+	select {
+	case d := <-sub.C():
+		t.Fatal("unchanged refresh shouldn't publish diff:", d)
+	case <-time.After(30 * time.Millisecond):
+	}
+}
+
+func TestSubscriptionDoesntBlockRefresh(t *testing.T) {
+	fetchFunc, next := versionedFetchFunc(
+		map[string]string{"key": "1"},
+		map[string]string{"key": "2"},
+		map[string]string{"key": "3"},
+		map[string]string{"key": "4"},
+	)
+
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, 5*time.Millisecond),
+	)
+	defer s.Stop()
+
+	// Subscription is never read
+	sub := s.Subscribe(0)
+
+	for i := 0; i < 3; i++ {
+		<-time.After(15 * time.Millisecond)
+		next()
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		item, err := s.Get("key")
+		if err == nil && item.Value() == "4" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("refresh should continue with a blocked subscriber")
+		}
+		<-time.After(5 * time.Millisecond)
+	}
+
+	if sub.Dropped() == 0 {
+		t.Fatal("undelivered diffs should be counted as dropped")
+	}
+}
+
+func TestSubscriptionClosedOnStop(t *testing.T) {
+	s := cache.NewSource("test")
+	sub := s.Subscribe(1)
+	s.Stop()
+
+	if _, ok := <-sub.C(); ok {
+		t.Fatal("subscription channel should be closed on stop")
+	}
+
+	// Closing already closed subscription is a no-op
+	sub.Close()
+
+	if _, ok := <-s.Subscribe(1).C(); ok {
+		t.Fatal("subscription to a stopped source should be closed")
+	}
+}
+
+func TestCacheOnChange(t *testing.T) {
+	fetchFunc1, next1 := versionedFetchFunc(
+		map[string]string{"key": "1"},
+		map[string]string{"key": "2"},
+	)
+	fetchFunc2, next2 := versionedFetchFunc(
+		map[string]string{"key": "1"},
+		map[string]string{"key": "2"},
+	)
+
+	s1 := cache.NewSource(
+		"s1",
+		cache.WithDefaultData(map[string]string{"key": "1"}),
+		cache.WithFetchFunc(fetchFunc1, 5*time.Millisecond),
+	)
+	defer s1.Stop()
+	s2 := cache.NewSource(
+		"s2",
+		cache.WithDefaultData(map[string]string{"key": "1"}),
+		cache.WithFetchFunc(fetchFunc2, 5*time.Millisecond),
+	)
+	defer s2.Stop()
+
+	c := cache.New(s1, s2)
+
+	diffs := make(chan cache.Diff, 10)
+	cancel := c.OnChange(func(d cache.Diff) {
+		diffs <- d
+	})
+	defer cancel()
+
+	next1()
+	next2()
+
+	seen := map[string]bool{}
+	for len(seen) < 2 {
+		select {
+		case d := <-diffs:
+			if d.Modified["key"].New != "2" {
+				t.Fatal("wrong diff delivered:", d)
+			}
+			seen[d.Source] = true
+		case <-time.After(time.Second):
+			t.Fatal("diffs from all sources should be delivered, got:", seen)
+		}
+	}
+}
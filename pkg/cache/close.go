@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CloseError is returned by Close if some sources didn't stop before the
+// context was done
+type CloseError struct {
+	// Sources that were still stopping
+	Sources []string
+
+	// Err is the context error
+	Err error
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("sources %s didn't stop: %s", strings.Join(e.Sources, ", "), e.Err)
+}
+
+func (e *CloseError) Unwrap() error {
+	return e.Err
+}
+
+// Close stops all sources concurrently. Stopping a source cancels its
+// in-flight fetches and waits for running refreshes, including snapshot
+// writes, to finish. If ctx is done before all sources stop, Close returns a
+// CloseError listing them while they keep stopping in background. Close
+// returns ErrClosed if it was already called.
+func (c *cacheImpl) Close(ctx context.Context) error {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return ErrClosed
+	}
+	c.closed = true
+	sources := c.sources
+	for _, unlisten := range c.unlisten {
+		unlisten()
+	}
+	c.sources = map[string]Source{}
+	c.unlisten = map[string]func(){}
+	c.lock.Unlock()
+
+	stopped := make(chan string, len(sources))
+	for name, s := range sources {
+		go func(name string, s Source) {
+			stopSource(s)
+			stopped <- name
+		}(name, s)
+	}
+
+	pending := make(map[string]struct{}, len(sources))
+	for name := range sources {
+		pending[name] = struct{}{}
+	}
+
+wait:
+	for len(pending) > 0 {
+		select {
+		case name := <-stopped:
+			delete(pending, name)
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	c.notifier.close()
+
+	if len(pending) == 0 {
+		if c.scheduler != nil {
+			c.scheduler.Stop()
+		}
+		c.logger.Debug("cache closed")
+		return nil
+	}
+
+	// Scheduler waits for refreshes of sources that are still stopping
+	if c.scheduler != nil {
+		go c.scheduler.Stop()
+	}
+
+	err := &CloseError{Err: ctx.Err()}
+	for name := range pending {
+		err.Sources = append(err.Sources, name)
+	}
+	sort.Strings(err.Sources)
+
+	c.logger.Error("sources didn't stop", "sources", err.Sources, "error", err.Err)
+	return err
+}
@@ -0,0 +1,98 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+func TestCacheClose(t *testing.T) {
+	fetchFunc, _ := versionedFetchFunc(map[string]string{"key": "value"})
+	s1 := cache.NewSource("s1", cache.WithFetchFunc(fetchFunc, time.Hour))
+	s2 := cache.NewStaticSource("s2", map[string]string{"key": "value"}, time.Now())
+	c := cache.New(s1, s2)
+
+	sub := c.Subscribe(1)
+
+	if err := c.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s1.Refresh(context.Background()); err != cache.ErrStopped {
+		t.Fatal("sources should be stopped, got:", err)
+	}
+
+	// Diff of the initial fetch of s1 may be delivered before Close
+	timeout := time.After(time.Second)
+	for closed := false; !closed; {
+		select {
+		case _, ok := <-sub.C():
+			closed = !ok
+		case <-timeout:
+			t.Fatal("subscriptions should be closed")
+		}
+	}
+
+	if _, err := c.Get("s2", "key"); err != cache.ErrClosed {
+		t.Fatal("get after close should fail, got:", err)
+	}
+	if v, err := c.GetString("s2", "key", "default"); err != cache.ErrClosed || v != "default" {
+		t.Fatal("typed get after close should return default, got:", v, err)
+	}
+	if err := c.Add(s2); err != cache.ErrClosed {
+		t.Fatal("add after close should fail, got:", err)
+	}
+	if len(c.Sources()) != 0 || len(c.Status()) != 0 {
+		t.Fatal("closed cache shouldn't have sources")
+	}
+	if err := c.RefreshAll(context.Background()); err != cache.ErrClosed {
+		t.Fatal("refresh after close should fail, got:", err)
+	}
+	if err := c.WaitReady(context.Background()); err != cache.ErrClosed {
+		t.Fatal("wait after close should fail, got:", err)
+	}
+	if err := c.Remove("s2"); err != cache.ErrClosed {
+		t.Fatal("remove after close should fail, got:", err)
+	}
+	if _, ok := <-c.Subscribe(1).C(); ok {
+		t.Fatal("subscription after close should be closed")
+	}
+	if err := c.Close(context.Background()); err != cache.ErrClosed {
+		t.Fatal("second close should fail, got:", err)
+	}
+}
+
+func TestCacheCloseDeadline(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	// Fetch function ignores cancellation, so the source can't stop
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		close(started)
+		<-release
+		return map[string]string{}, nil
+	}
+	s1 := cache.NewSource("slow", cache.WithFetchFunc(fetchFunc, time.Hour))
+	s2 := cache.NewStaticSource("static", nil, time.Now())
+	c := cache.NewWithOptions([]cache.Source{s1, s2}, cache.WithCacheLogger(cache.NopLogger()))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := c.Close(ctx)
+	var closeErr *cache.CloseError
+	if !errors.As(err, &closeErr) {
+		t.Fatal("close should report sources that didn't stop, got:", err)
+	}
+	if len(closeErr.Sources) != 1 || closeErr.Sources[0] != "slow" {
+		t.Fatal("unexpected sources:", closeErr.Sources)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("close error should wrap context error")
+	}
+}
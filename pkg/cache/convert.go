@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidValue is matched by errors returned from Item conversions
+var ErrInvalidValue = errors.New("Invalid value")
+
+// ValueError is returned when an item value can't be converted to requested
+// type. It matches ErrInvalidValue with errors.Is.
+type ValueError struct {
+	Value string
+	Type  string
+	Err   error
+}
+
+func (e *ValueError) Error() string {
+	return fmt.Sprintf("cannot convert %q to %s: %v", e.Value, e.Type, e.Err)
+}
+
+func (e *ValueError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes ValueError match ErrInvalidValue
+func (e *ValueError) Is(target error) bool {
+	return target == ErrInvalidValue
+}
+
+func (i *item) Int() (int, error) {
+	v, err := strconv.Atoi(i.value)
+	if err != nil {
+		return 0, &ValueError{Value: i.value, Type: "int", Err: err}
+	}
+	return v, nil
+}
+
+func (i *item) Int64() (int64, error) {
+	v, err := strconv.ParseInt(i.value, 10, 64)
+	if err != nil {
+		return 0, &ValueError{Value: i.value, Type: "int64", Err: err}
+	}
+	return v, nil
+}
+
+func (i *item) Float64() (float64, error) {
+	v, err := strconv.ParseFloat(i.value, 64)
+	if err != nil {
+		return 0, &ValueError{Value: i.value, Type: "float64", Err: err}
+	}
+	return v, nil
+}
+
+func (i *item) Bool() (bool, error) {
+	v, err := strconv.ParseBool(i.value)
+	if err != nil {
+		return false, &ValueError{Value: i.value, Type: "bool", Err: err}
+	}
+	return v, nil
+}
+
+func (i *item) Duration() (time.Duration, error) {
+	v, err := time.ParseDuration(i.value)
+	if err != nil {
+		return 0, &ValueError{Value: i.value, Type: "time.Duration", Err: err}
+	}
+	return v, nil
+}
+
+func (i *item) Time(layout string) (time.Time, error) {
+	v, err := time.Parse(layout, i.value)
+	if err != nil {
+		return time.Time{}, &ValueError{Value: i.value, Type: "time.Time", Err: err}
+	}
+	return v, nil
+}
+
+func (i *item) Unmarshal(v interface{}) error {
+	if err := json.Unmarshal([]byte(i.value), v); err != nil {
+		return &ValueError{Value: i.value, Type: fmt.Sprintf("%T", v), Err: err}
+	}
+	return nil
+}
+
+// getOrDefault returns an item from the cache. Missing source or key isn't
+// reported as an error so the caller falls back to a default.
+func (c *cacheImpl) getOrDefault(source, key string) (Item, error) {
+	item, err := c.Get(source, key)
+	if err == ErrSourceNotFound || err == ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// GetString returns the value of key or def if the source or key doesn't
+// exist
+func (c *cacheImpl) GetString(source, key string, def string) (string, error) {
+	item, err := c.getOrDefault(source, key)
+	if item == nil {
+		return def, err
+	}
+	return item.Value(), nil
+}
+
+// GetInt returns the value of key converted to int or def if the source or
+// key doesn't exist. Def is also returned along with conversion errors.
+func (c *cacheImpl) GetInt(source, key string, def int) (int, error) {
+	item, err := c.getOrDefault(source, key)
+	if item == nil {
+		return def, err
+	}
+	v, err := item.Int()
+	if err != nil {
+		return def, err
+	}
+	return v, nil
+}
+
+// GetFloat64 returns the value of key converted to float64 or def if the
+// source or key doesn't exist. Def is also returned along with conversion
+// errors.
+func (c *cacheImpl) GetFloat64(source, key string, def float64) (float64, error) {
+	item, err := c.getOrDefault(source, key)
+	if item == nil {
+		return def, err
+	}
+	v, err := item.Float64()
+	if err != nil {
+		return def, err
+	}
+	return v, nil
+}
+
+// GetBool returns the value of key converted to bool or def if the source or
+// key doesn't exist. Def is also returned along with conversion errors.
+func (c *cacheImpl) GetBool(source, key string, def bool) (bool, error) {
+	item, err := c.getOrDefault(source, key)
+	if item == nil {
+		return def, err
+	}
+	v, err := item.Bool()
+	if err != nil {
+		return def, err
+	}
+	return v, nil
+}
+
+// GetDuration returns the value of key converted to time.Duration or def if
+// the source or key doesn't exist. Def is also returned along with
+// conversion errors.
+func (c *cacheImpl) GetDuration(source, key string, def time.Duration) (time.Duration, error) {
+	item, err := c.getOrDefault(source, key)
+	if item == nil {
+		return def, err
+	}
+	v, err := item.Duration()
+	if err != nil {
+		return def, err
+	}
+	return v, nil
+}
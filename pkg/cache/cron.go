@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronDescriptors are shortcuts for common cron expressions
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	cronMonths = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	cronDays   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// cronMaxYears bounds the search for the next time of expressions that
+// never match, e.g. February 30
+const cronMaxYears = 10
+
+// cron is a parsed cron expression, each field is a bit set of allowed values
+type cron struct {
+	minute, hour, dom, month, dow uint64
+
+	// Unrestricted day fields, if both day fields are restricted a day
+	// matching either of them matches
+	anyDom, anyDow bool
+
+	loc *time.Location
+}
+
+// Cron returns a schedule refreshing at times matching a standard five field
+// cron expression "minute hour day-of-month month day-of-week" evaluated in
+// loc. Fields support *, lists, ranges, steps and month and day names, as well
+// as descriptors like @hourly or @daily. A nil loc uses local time.
+//
+//	// Every 15 minutes during working hours in New York
+//	loc, _ := time.LoadLocation("America/New_York")
+//	s, err := cache.Cron("*/15 9-17 * * mon-fri", loc)
+func Cron(expr string, loc *time.Location) (Schedule, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	spec := strings.TrimSpace(expr)
+	if d, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields", expr)
+	}
+
+	c := &cron{
+		loc:    loc,
+		anyDom: fields[2] == "*",
+		anyDow: fields[4] == "*",
+	}
+
+	var err error
+	for _, f := range []struct {
+		set      *uint64
+		field    string
+		min, max int
+		names    []string
+	}{
+		{&c.minute, fields[0], 0, 59, nil},
+		{&c.hour, fields[1], 0, 23, nil},
+		{&c.dom, fields[2], 1, 31, nil},
+		{&c.month, fields[3], 1, 12, cronMonths},
+		{&c.dow, fields[4], 0, 7, cronDays},
+	} {
+		if *f.set, err = parseCronField(f.field, f.min, f.max, f.names); err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+	}
+
+	// Both 0 and 7 are Sunday
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+
+	return c, nil
+}
+
+// MustCron is like Cron but panics if the expression can't be parsed
+func MustCron(expr string, loc *time.Location) Schedule {
+	s, err := Cron(expr, loc)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// parseCronField parses a comma separated list of values, ranges and steps
+// into a bit set
+func parseCronField(field string, min, max int, names []string) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			var err error
+			rng = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = parseCronValue(bounds[0], min, max, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = parseCronValue(bounds[1], min, max, names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// A step without range runs from the value to the maximum
+				hi = max
+			}
+		}
+
+		if lo > hi {
+			return 0, fmt.Errorf("invalid range %q", rng)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func parseCronValue(s string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.EqualFold(s, name) {
+			return i + min, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("invalid value %q, expected %d-%d", s, min, max)
+	}
+	return v, nil
+}
+
+// Next returns the first matching minute after t. Fields are matched from
+// the month down, skipping whole months, days and hours that don't match.
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.In(c.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronMaxYears, 0, 0)
+
+	for t.Before(limit) {
+		y, m, d := t.Date()
+		var next time.Time
+
+		switch {
+		case !has(c.month, int(m)):
+			next = time.Date(y, m+1, 1, 0, 0, 0, 0, c.loc)
+		case !c.dayMatches(t):
+			next = time.Date(y, m, d+1, 0, 0, 0, 0, c.loc)
+		case !has(c.hour, t.Hour()):
+			next = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, c.loc)
+		case !has(c.minute, t.Minute()):
+			next = t.Add(time.Minute)
+		default:
+			return t
+		}
+
+		// Wall-clock times skipped by daylight saving changes may be
+		// normalized backwards, always move forward
+		if !next.After(t) {
+			next = t.Truncate(time.Minute).Add(time.Minute)
+		}
+		t = next
+	}
+	return Never
+}
+
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := has(c.dom, t.Day())
+	dow := has(c.dow, int(t.Weekday()))
+	if c.anyDom || c.anyDow {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<v) != 0
+}
@@ -1,10 +1,11 @@
 package cache
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 func NewDbSource(
@@ -15,21 +16,41 @@ func NewDbSource(
 	frequency time.Duration,
 	opts ...Option,
 ) StoppableSource {
-	opts = append(opts, WithFetchFunc(
+	return NewSource(name, dbSourceOptions(driverName, connStr, query, frequency, opts)...)
+}
+
+// NewDbSourceE is like NewDbSource but returns an error if the initial load
+// set with WithInitialLoadTimeout fails
+func NewDbSourceE(
+	name string,
+	driverName string,
+	connStr string,
+	query *DbQuery,
+	frequency time.Duration,
+	opts ...Option,
+) (StoppableSource, error) {
+	return NewSourceE(name, dbSourceOptions(driverName, connStr, query, frequency, opts)...)
+}
+
+func dbSourceOptions(
+	driverName string,
+	connStr string,
+	query *DbQuery,
+	frequency time.Duration,
+	opts []Option,
+) []Option {
+	return append(opts, WithBytesFetchFunc(
 		dbFetchFunc(driverName, connStr, query),
 		frequency,
 	))
-	return NewSource(
-		name,
-		opts...,
-	)
 }
 
 // DbQuery is a way to pass SQL query into DbQuery cache source
 type DbQuery struct {
 	// Query is a SQL query that will be executed against the database. It must
-	// be in format `SELECT key, value FROM ..`, where both `key` and `value`
-	// colums must be `string` type.
+	// be in format `SELECT key, value FROM ..`, where `key` column must be
+	// `string` type and `value` column can be either text or binary (e.g.
+	// `bytea`) type.
 	Query string
 
 	// Query arguments
@@ -40,31 +61,72 @@ func dbFetchFunc(
 	driverName string,
 	connStr string,
 	query *DbQuery,
-) func() (map[string]string, error) {
+) BytesFetchFunc {
 	db, err := sql.Open(driverName, connStr)
-	return func() (map[string]string, error) {
+	return func(ctx context.Context) (map[string][]byte, error) {
 		if err != nil {
 			return nil, err
 		}
 
-		rows, err := db.Query(query.Query, query.Args...)
+		return dbQueryRows(ctx, db, query.Query, query.Args...)
+	}
+}
+
+// NewDbBatchLoadFunc returns a BatchLoadFunc for read-through sources backed
+// by a PostgreSQL database. The query must be in format
+// `SELECT key, value FROM .. WHERE key = ANY($n)`, the keys are passed as an
+// array in the last argument after query Args.
+func NewDbBatchLoadFunc(
+	driverName string,
+	connStr string,
+	query *DbQuery,
+) BatchLoadFunc {
+	db, err := sql.Open(driverName, connStr)
+	return func(ctx context.Context, keys []string) (map[string]string, error) {
 		if err != nil {
 			return nil, err
 		}
 
-		data := map[string]string{}
-		for rows.Next() {
-			var key string
-			var value string
+		args := append(append([]interface{}(nil), query.Args...), pq.Array(keys))
+		rows, err := dbQueryRows(ctx, db, query.Query, args...)
+		if err != nil {
+			return nil, err
+		}
+
+		data := make(map[string]string, len(rows))
+		for key, value := range rows {
+			data[key] = bytesToString(value)
+		}
+		return data, nil
+	}
+}
+
+// dbQueryRows runs a query selecting key and value columns
+func dbQueryRows(ctx context.Context, db *sql.DB, query string, args ...interface{}) (map[string][]byte, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-			err := rows.Scan(&key, &value)
-			if err != nil {
-				return nil, err
-			}
+	data := map[string][]byte{}
+	for rows.Next() {
+		var key string
+		// Scanning into []byte copies the value, so it's safe to keep it
+		// after the next row is read
+		var value []byte
 
-			data[key] = value
+		err := rows.Scan(&key, &value)
+		if err != nil {
+			return nil, err
 		}
 
-		return data, nil
+		data[key] = value
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
+
+	return data, nil
 }
@@ -117,7 +117,9 @@ func TestDbSource(t *testing.T) {
 	)
 	defer s.Stop()
 
-	<-time.After(200 * time.Millisecond)
+	if err := s.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
 
 	item, err := s.Get("key")
 	if err != nil {
@@ -164,4 +166,32 @@ func TestDbSource(t *testing.T) {
 	if item.Value() != "new-value" {
 		t.Fatal("wrong value was returned for `new-key`")
 	}
+
+	// Keys missing in the bulk data are loaded in batches
+	rt := cache.NewSource(
+		"db_read_through",
+		cache.WithBatchLoadFunc(
+			cache.NewDbBatchLoadFunc(
+				"postgres",
+				connStr,
+				&cache.DbQuery{
+					Query: "SELECT key, value FROM cache WHERE key = ANY($1)",
+				},
+			),
+			time.Minute,
+		),
+	)
+	defer rt.Stop()
+
+	item, err = rt.Get("new-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Value() != "new-value" {
+		t.Fatal("wrong value was loaded for `new-key`")
+	}
+
+	if _, err := rt.Get("missing-key"); err != cache.ErrKeyNotFound {
+		t.Fatal("missing key should return ErrKeyNotFound, got:", err)
+	}
 }
@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"hash/maphash"
+)
+
+// EvictionPolicy decides which keys loaded in read-through mode are evicted
+// when the source reaches its limits. Methods are called with the store lock
+// held, so implementations don't need to be safe for concurrent use, but an
+// instance must not be shared by multiple sources.
+type EvictionPolicy interface {
+	// Access is called on every lookup of a read-through key, including
+	// lookups of keys that aren't loaded yet
+	Access(key string)
+
+	// Add is called when a key is stored
+	Add(key string)
+
+	// Remove is called when a key is evicted or deleted
+	Remove(key string)
+
+	// Victim returns the key that should be evicted next
+	Victim() (string, bool)
+
+	// Admit is called when storing candidate requires evicting victim.
+	// Returning false drops the candidate and keeps the stored keys.
+	Admit(candidate, victim string) bool
+}
+
+// EvictionFunc is called with keys evicted to respect the limits set by
+// WithLoadLimits, including loaded keys that weren't admitted by the policy.
+// Evicted missing keys are passed with notFound set. It's called from the
+// goroutine that loaded the key and should return quickly.
+type EvictionFunc func(key, value string, notFound bool)
+
+// LRU returns a policy evicting the least recently used key
+func LRU() EvictionPolicy {
+	return &lru{
+		order: list.New(),
+		keys:  map[string]*list.Element{},
+	}
+}
+
+type lru struct {
+	order *list.List
+	keys  map[string]*list.Element
+}
+
+func (p *lru) Access(key string) {
+	if e, ok := p.keys[key]; ok {
+		p.order.MoveToFront(e)
+	}
+}
+
+func (p *lru) Add(key string) {
+	if e, ok := p.keys[key]; ok {
+		p.order.MoveToFront(e)
+		return
+	}
+	p.keys[key] = p.order.PushFront(key)
+}
+
+func (p *lru) Remove(key string) {
+	if e, ok := p.keys[key]; ok {
+		p.order.Remove(e)
+		delete(p.keys, key)
+	}
+}
+
+func (p *lru) Victim() (string, bool) {
+	e := p.order.Back()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
+
+func (p *lru) Admit(candidate, victim string) bool {
+	return true
+}
+
+// LFU returns a policy evicting the least frequently used key. Keys with the
+// same frequency are evicted in the order they were added.
+func LFU() EvictionPolicy {
+	return &lfu{
+		keys: map[string]*lfuEntry{},
+	}
+}
+
+type lfuEntry struct {
+	key   string
+	freq  uint64
+	seq   uint64
+	index int
+}
+
+// lfuHeap orders entries by frequency and insertion
+type lfuHeap []*lfuEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+	e := x.(*lfuEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return e
+}
+
+type lfu struct {
+	heap lfuHeap
+	keys map[string]*lfuEntry
+	seq  uint64
+}
+
+func (p *lfu) Access(key string) {
+	if e, ok := p.keys[key]; ok {
+		e.freq++
+		heap.Fix(&p.heap, e.index)
+	}
+}
+
+func (p *lfu) Add(key string) {
+	if _, ok := p.keys[key]; ok {
+		p.Access(key)
+		return
+	}
+	p.seq++
+	e := &lfuEntry{key: key, freq: 1, seq: p.seq}
+	p.keys[key] = e
+	heap.Push(&p.heap, e)
+}
+
+func (p *lfu) Remove(key string) {
+	if e, ok := p.keys[key]; ok {
+		heap.Remove(&p.heap, e.index)
+		delete(p.keys, key)
+	}
+}
+
+func (p *lfu) Victim() (string, bool) {
+	if len(p.heap) == 0 {
+		return "", false
+	}
+	return p.heap[0].key, true
+}
+
+func (p *lfu) Admit(candidate, victim string) bool {
+	return true
+}
+
+// TinyLFU returns a policy that evicts the least recently used key but only
+// admits a new key if it was requested more often than the key it would
+// replace. Access frequencies, including of keys that aren't stored, are
+// estimated by a count-min sketch sized for capacity keys and periodically
+// halved, so one-off lookups don't flush frequently used keys.
+func TinyLFU(capacity int) EvictionPolicy {
+	return &tinyLFU{
+		lru:    LRU().(*lru),
+		sketch: newCountMinSketch(capacity),
+	}
+}
+
+type tinyLFU struct {
+	*lru
+	sketch *countMinSketch
+}
+
+func (p *tinyLFU) Access(key string) {
+	p.sketch.increment(key)
+	p.lru.Access(key)
+}
+
+func (p *tinyLFU) Admit(candidate, victim string) bool {
+	return p.sketch.estimate(candidate) > p.sketch.estimate(victim)
+}
+
+const sketchDepth = 4
+
+// countMinSketch estimates key frequencies in constant memory
+type countMinSketch struct {
+	seeds     [sketchDepth]maphash.Seed
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+func newCountMinSketch(capacity int) *countMinSketch {
+	width := 16
+	for width < capacity {
+		width *= 2
+	}
+
+	s := &countMinSketch{
+		mask:    uint64(width - 1),
+		resetAt: 10 * width,
+	}
+	for i := range s.rows {
+		s.seeds[i] = maphash.MakeSeed()
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *countMinSketch) increment(key string) {
+	for i := range s.rows {
+		c := &s.rows[i][maphash.String(s.seeds[i], key)&s.mask]
+		if *c < 255 {
+			*c++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *countMinSketch) estimate(key string) uint8 {
+	min := uint8(255)
+	for i := range s.rows {
+		if c := s.rows[i][maphash.String(s.seeds[i], key)&s.mask]; c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+// reset halves all counters so old frequencies fade out
+func (s *countMinSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] /= 2
+		}
+	}
+	s.additions /= 2
+}
@@ -0,0 +1,167 @@
+package cache_test
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+func countingLoadFunc(loads *int32) cache.LoadFunc {
+	return func(ctx context.Context, key string) (string, error) {
+		atomic.AddInt32(loads, 1)
+		return "loaded-" + key, nil
+	}
+}
+
+func TestLoadLimitsLRU(t *testing.T) {
+	var loads int32
+	var evicted []string
+
+	s := cache.NewSource(
+		"test",
+		cache.WithLoadFunc(countingLoadFunc(&loads), time.Hour),
+		cache.WithLoadLimits(2, 0),
+		cache.WithEvictionFunc(func(key, value string, notFound bool) {
+			evicted = append(evicted, key+"="+value)
+		}),
+	)
+	defer s.Stop()
+
+	s.Get("a")
+	s.Get("b")
+	s.Get("a")
+	s.Get("c")
+
+	if len(evicted) != 1 || evicted[0] != "b=loaded-b" {
+		t.Fatal("least recently used key should be evicted, got:", evicted)
+	}
+
+	status := s.Status()
+	if status.LoadedKeys != 2 || status.Evictions != 1 {
+		t.Fatal("unexpected status:", status.LoadedKeys, status.Evictions)
+	}
+
+	s.Get("a")
+	s.Get("c")
+	if l := atomic.LoadInt32(&loads); l != 3 {
+		t.Fatal("kept keys shouldn't be reloaded, loads:", l)
+	}
+}
+
+func TestLoadLimitsLFU(t *testing.T) {
+	var loads int32
+	var evicted []string
+
+	s := cache.NewSource(
+		"test",
+		cache.WithLoadFunc(countingLoadFunc(&loads), time.Hour),
+		cache.WithLoadLimits(2, 0),
+		cache.WithEvictionPolicy(cache.LFU()),
+		cache.WithEvictionFunc(func(key, value string, notFound bool) {
+			evicted = append(evicted, key)
+		}),
+	)
+	defer s.Stop()
+
+	s.Get("a")
+	s.Get("a")
+	s.Get("a")
+	s.Get("b")
+	s.Get("b")
+	s.Get("c")
+	s.Get("d")
+
+	if strings.Join(evicted, ",") != "b,c" {
+		t.Fatal("least frequently used keys should be evicted, got:", evicted)
+	}
+
+	s.Get("a")
+	if l := atomic.LoadInt32(&loads); l != 4 {
+		t.Fatal("frequently used key shouldn't be reloaded, loads:", l)
+	}
+}
+
+func TestLoadLimitsBytes(t *testing.T) {
+	var loads int32
+
+	s := cache.NewSource(
+		"test",
+		cache.WithDefaultData(map[string]string{"bulk": strings.Repeat("x", 100)}),
+		cache.WithLoadFunc(countingLoadFunc(&loads), time.Hour),
+		cache.WithLoadLimits(0, 18),
+	)
+	defer s.Stop()
+
+	// Each entry takes 9 bytes, key and "loaded-" prefixed value
+	s.Get("a")
+	s.Get("b")
+	status := s.Status()
+	if status.LoadedKeys != 2 || status.LoadedBytes != 18 || status.Evictions != 0 {
+		t.Fatal("entries should fit the limit:", status.LoadedKeys, status.LoadedBytes)
+	}
+
+	s.Get("c")
+	status = s.Status()
+	if status.LoadedKeys != 2 || status.LoadedBytes != 18 || status.Evictions != 1 {
+		t.Fatal("entry should be evicted:", status.LoadedKeys, status.LoadedBytes)
+	}
+
+	item, err := s.Get("too-long-key-for-the-limit")
+	if err != nil || item.Value() != "loaded-too-long-key-for-the-limit" {
+		t.Fatal("entry over the limit should be served")
+	}
+	if s.Status().LoadedKeys != 2 {
+		t.Fatal("entry over the limit shouldn't be stored")
+	}
+
+	if s.Status().Keys != 1 {
+		t.Fatal("bulk data shouldn't be limited")
+	}
+}
+
+func TestLoadLimitsTinyLFU(t *testing.T) {
+	var loads int32
+	var evicted []string
+
+	s := cache.NewSource(
+		"test",
+		cache.WithLoadFunc(countingLoadFunc(&loads), time.Hour),
+		cache.WithLoadLimits(2, 0),
+		cache.WithEvictionPolicy(cache.TinyLFU(100)),
+		cache.WithEvictionFunc(func(key, value string, notFound bool) {
+			evicted = append(evicted, key)
+		}),
+	)
+	defer s.Stop()
+
+	for i := 0; i < 5; i++ {
+		s.Get("a")
+		s.Get("b")
+	}
+
+	// One-off key isn't admitted and is loaded on each Get
+	item, err := s.Get("c")
+	if err != nil || item.Value() != "loaded-c" {
+		t.Fatal("not admitted key should be served")
+	}
+	if strings.Join(evicted, ",") != "c" {
+		t.Fatal("not admitted key should be reported as evicted, got:", evicted)
+	}
+
+	// Key requested often enough replaces the least recently used key
+	for i := 0; i < 10; i++ {
+		s.Get("d")
+	}
+	if strings.Join(evicted, ",") != "c,d,d,d,d,d,a" {
+		t.Fatal("frequent key should be admitted, got:", evicted)
+	}
+
+	status := s.Status()
+	if status.LoadedKeys != 2 || status.Evictions != 7 {
+		t.Fatal("unexpected status:", status.LoadedKeys, status.Evictions)
+	}
+}
@@ -62,6 +62,21 @@ type Item interface {
 	Metadata
 
 	Value() string
+
+	// ValueBytes returns the value as a read-only byte slice without copying
+	ValueBytes() []byte
+
+	// Conversions of the value. Errors returned from conversions match
+	// ErrInvalidValue.
+	Int() (int, error)
+	Int64() (int64, error)
+	Float64() (float64, error)
+	Bool() (bool, error)
+	Duration() (time.Duration, error)
+	Time(layout string) (time.Time, error)
+
+	// Unmarshal decodes JSON value into v
+	Unmarshal(v interface{}) error
 }
 
 type item struct {
@@ -1,6 +1,8 @@
 package cache_test
 
 import (
+	"errors"
+	"strconv"
 	"testing"
 	"time"
 
@@ -55,3 +57,63 @@ func TestItem(t *testing.T) {
 		t.Fatal("Value of the cached item is incorrect")
 	}
 }
+
+func TestItemConversions(t *testing.T) {
+	item := func(v string) cache.Item {
+		return cache.NewItem(v, time.Now(), cache.Never)
+	}
+
+	if v, err := item("42").Int(); err != nil || v != 42 {
+		t.Fatal("wrong int conversion", v, err)
+	}
+	if v, err := item("-42").Int64(); err != nil || v != -42 {
+		t.Fatal("wrong int64 conversion", v, err)
+	}
+	if v, err := item("1.5").Float64(); err != nil || v != 1.5 {
+		t.Fatal("wrong float64 conversion", v, err)
+	}
+	if v, err := item("true").Bool(); err != nil || !v {
+		t.Fatal("wrong bool conversion", v, err)
+	}
+	if v, err := item("1m30s").Duration(); err != nil || v != 90*time.Second {
+		t.Fatal("wrong duration conversion", v, err)
+	}
+	if v, err := item("2019-03-01").Time("2006-01-02"); err != nil || v.Month() != time.March {
+		t.Fatal("wrong time conversion", v, err)
+	}
+
+	var m map[string]int
+	if err := item(`{"a": 1}`).Unmarshal(&m); err != nil || m["a"] != 1 {
+		t.Fatal("wrong json conversion", m, err)
+	}
+}
+
+func TestItemConversionErrors(t *testing.T) {
+	i := cache.NewItem("invalid", time.Now(), cache.Never)
+
+	_, err := i.Int()
+	if !errors.Is(err, cache.ErrInvalidValue) {
+		t.Fatal("conversion error should match invalid value error")
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Fatal("conversion error should wrap parse error")
+	}
+
+	errs := []error{}
+	_, err = i.Float64()
+	errs = append(errs, err)
+	_, err = i.Bool()
+	errs = append(errs, err)
+	_, err = i.Duration()
+	errs = append(errs, err)
+	_, err = i.Time(time.RFC3339)
+	errs = append(errs, err)
+	errs = append(errs, i.Unmarshal(&struct{}{}))
+
+	for _, err := range errs {
+		var valueErr *cache.ValueError
+		if !errors.As(err, &valueErr) || valueErr.Value != "invalid" {
+			t.Fatal("conversion should return value error, got:", err)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// LoadFunc loads a single key for a read-through source. It should return
+// ErrKeyNotFound if the key doesn't exist.
+type LoadFunc func(ctx context.Context, key string) (string, error)
+
+// lazyEntry is a single key loaded by LoadFunc
+type lazyEntry struct {
+	value    string
+	notFound bool
+	loadedAt time.Time
+	expires  time.Time
+}
+
+// size is the number of bytes counted against the load limits
+func (e *lazyEntry) size(key string) int64 {
+	return int64(len(key) + len(e.value))
+}
+
+func (e *lazyEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+func (e *lazyEntry) item() Item {
+	return NewItem(e.value, e.loadedAt, e.expires)
+}
+
+// result returns the entry as a Get result, the loaded flag is always false
+func (e *lazyEntry) result() (Item, bool, error) {
+	if e.notFound {
+		return nil, false, ErrKeyNotFound
+	}
+	return e.item(), false, nil
+}
+
+// loadCall is a single load shared by concurrent Get calls of the same key
+type loadCall struct {
+	done  chan struct{}
+	entry *lazyEntry
+	err   error
+}
+
+// lazyStore keeps keys loaded on demand by a read-through source
+type lazyStore struct {
+	load        LoadFunc
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	// Limits enforced by evicting entries chosen by policy
+	maxKeys  int
+	maxBytes int64
+	policy   EvictionPolicy
+	onEvict  EvictionFunc
+
+	// batch collects keys for BatchLoadFunc, nil if loading key by key
+	batch *batchLoader
+
+	lock      sync.Mutex
+	entries   map[string]*lazyEntry
+	inflight  map[string]*loadCall
+	bytes     int64
+	evictions uint64
+}
+
+func newLazyStore(o *Options) *lazyStore {
+	l := &lazyStore{
+		load:        o.LoadFunc,
+		ttl:         o.LoadTTL,
+		negativeTTL: o.NegativeTTL,
+		maxKeys:     o.MaxLoadedKeys,
+		maxBytes:    o.MaxLoadedBytes,
+		policy:      o.EvictionPolicy,
+		onEvict:     o.EvictionFunc,
+		entries:     map[string]*lazyEntry{},
+		inflight:    map[string]*loadCall{},
+	}
+	if l.policy == nil {
+		l.policy = LRU()
+	}
+	if o.BatchLoadFunc != nil {
+		l.batch = &batchLoader{
+			load:    o.BatchLoadFunc,
+			window:  o.BatchWindow,
+			maxSize: o.MaxBatchSize,
+		}
+	}
+	return l
+}
+
+// get returns a cached entry or loads it. The loaded flag reports whether
+// the key had to be loaded. If a load fails the expired entry is served.
+func (l *lazyStore) get(s *source, key string) (item Item, loaded bool, err error) {
+	now := time.Now()
+
+	l.lock.Lock()
+	l.policy.Access(key)
+	e, ok := l.entries[key]
+	if ok && !e.expired(now) {
+		l.lock.Unlock()
+		return e.result()
+	}
+
+	c, running := l.inflight[key]
+	if !running {
+		c = &loadCall{done: make(chan struct{})}
+		l.inflight[key] = c
+	}
+	l.lock.Unlock()
+
+	if !running {
+		if l.batch != nil {
+			l.batch.add(s, l, key, c)
+		} else {
+			l.run(s, key, c)
+		}
+	}
+	<-c.done
+
+	if c.err != nil {
+		if ok {
+			// Serve expired entry rather than failing
+			item, _, err := e.result()
+			return item, true, err
+		}
+		return nil, true, c.err
+	}
+
+	item, _, err = c.entry.result()
+	return item, true, err
+}
+
+// run executes the load of a single key and stores the result
+func (l *lazyStore) run(s *source, key string, c *loadCall) {
+	ctx, cancel := s.fetchContext()
+	defer cancel()
+
+	value, err := l.load(ctx, key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		s.logger.Warn("failed to load key", "source", s.name, "key", key, "error", err)
+	}
+
+	l.complete([]loadResult{{key: key, call: c, value: value, err: err}})
+}
+
+// loadResult is an outcome of loading a single key
+type loadResult struct {
+	key   string
+	call  *loadCall
+	value string
+	err   error
+}
+
+// complete stores loaded keys and releases Get calls waiting for them
+func (l *lazyStore) complete(results []loadResult) {
+	now := time.Now()
+	var evicted []evictedEntry
+
+	l.lock.Lock()
+	for _, r := range results {
+		c := r.call
+
+		var e *lazyEntry
+		switch {
+		case r.err == nil:
+			e = &lazyEntry{value: r.value, loadedAt: now}
+			if l.ttl > 0 {
+				e.expires = now.Add(l.ttl)
+			}
+		case errors.Is(r.err, ErrKeyNotFound) && l.negativeTTL > 0:
+			e = &lazyEntry{notFound: true, loadedAt: now, expires: now.Add(l.negativeTTL)}
+		case errors.Is(r.err, ErrKeyNotFound):
+			c.entry = &lazyEntry{notFound: true}
+		default:
+			c.err = r.err
+		}
+
+		delete(l.inflight, r.key)
+		if e != nil {
+			c.entry = e
+			evicted = append(evicted, l.store(r.key, e)...)
+		} else if c.entry != nil {
+			// Not found result that isn't cached removes the stale entry
+			l.delete(r.key)
+		}
+	}
+	l.lock.Unlock()
+
+	for _, r := range results {
+		close(r.call.done)
+	}
+
+	if l.onEvict != nil {
+		for _, e := range evicted {
+			l.onEvict(e.key, e.value, e.notFound)
+		}
+	}
+}
+
+// evictedEntry is an entry removed to respect the limits
+type evictedEntry struct {
+	key string
+	*lazyEntry
+}
+
+// store adds the entry and evicts entries exceeding the limits. It returns
+// evicted entries, which include the new entry if it wasn't admitted.
+func (l *lazyStore) store(key string, e *lazyEntry) []evictedEntry {
+	l.delete(key)
+
+	size := e.size(key)
+	if l.maxBytes > 0 && size > l.maxBytes {
+		l.evictions++
+		return []evictedEntry{{key, e}}
+	}
+
+	var evicted []evictedEntry
+	admitted := false
+	for l.full(size) {
+		victim, ok := l.policy.Victim()
+		if !ok {
+			break
+		}
+		if !admitted {
+			if !l.policy.Admit(key, victim) {
+				l.evictions++
+				return []evictedEntry{{key, e}}
+			}
+			admitted = true
+		}
+
+		evicted = append(evicted, evictedEntry{victim, l.entries[victim]})
+		l.delete(victim)
+		l.evictions++
+	}
+
+	l.entries[key] = e
+	l.bytes += size
+	l.policy.Add(key)
+	return evicted
+}
+
+// full returns true if adding size bytes would exceed the limits
+func (l *lazyStore) full(size int64) bool {
+	if l.maxKeys > 0 && len(l.entries) >= l.maxKeys {
+		return true
+	}
+	return l.maxBytes > 0 && l.bytes+size > l.maxBytes
+}
+
+func (l *lazyStore) delete(key string) {
+	e, ok := l.entries[key]
+	if !ok {
+		return
+	}
+	delete(l.entries, key)
+	l.bytes -= e.size(key)
+	l.policy.Remove(key)
+}
+
+// stats returns the number and total size of loaded keys and the number of
+// evicted keys
+func (l *lazyStore) stats() (keys int, bytes int64, evictions uint64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return len(l.entries), l.bytes, l.evictions
+}
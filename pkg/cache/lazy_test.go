@@ -0,0 +1,159 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+func TestReadThrough(t *testing.T) {
+	var loads int32
+	loadFunc := func(ctx context.Context, key string) (string, error) {
+		atomic.AddInt32(&loads, 1)
+		return "loaded-" + key, nil
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithDefaultData(map[string]string{"bulk": "value"}),
+		cache.WithLoadFunc(loadFunc, time.Hour),
+	)
+	defer s.Stop()
+
+	item, err := s.Get("bulk")
+	if err != nil || item.Value() != "value" {
+		t.Fatal("bulk data should be served without loading")
+	}
+
+	item, err = s.Get("key")
+	if err != nil || item.Value() != "loaded-key" {
+		t.Fatal("missing key should be loaded")
+	}
+	if !item.NextRefresh().Equal(item.LastRefreshed().Add(time.Hour)) {
+		t.Fatal("loaded item should expire after ttl")
+	}
+
+	s.Get("key")
+	if l := atomic.LoadInt32(&loads); l != 1 {
+		t.Fatal("loaded key should be cached, loads:", l)
+	}
+
+	if s.Status().LoadedKeys != 1 {
+		t.Fatal("loaded keys should be reported in status")
+	}
+}
+
+func TestReadThroughExpiry(t *testing.T) {
+	var version int32
+	var fail int32
+	loadFunc := func(ctx context.Context, key string) (string, error) {
+		if atomic.LoadInt32(&fail) == 1 {
+			return "", fmt.Errorf("error")
+		}
+		return fmt.Sprint(atomic.AddInt32(&version, 1)), nil
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithLoadFunc(loadFunc, 10*time.Millisecond),
+	)
+	defer s.Stop()
+
+	item, _ := s.Get("key")
+	if item.Value() != "1" {
+		t.Fatal("key should be loaded")
+	}
+
+	<-time.After(20 * time.Millisecond)
+
+	item, _ = s.Get("key")
+	if item.Value() != "2" {
+		t.Fatal("expired key should be reloaded")
+	}
+
+	<-time.After(20 * time.Millisecond)
+	atomic.StoreInt32(&fail, 1)
+
+	item, err := s.Get("key")
+	if err != nil || item.Value() != "2" {
+		t.Fatal("expired value should be served when reload fails")
+	}
+	if !item.IsStale() {
+		t.Fatal("expired value should be stale")
+	}
+
+	if _, err := s.Get("other"); err == nil {
+		t.Fatal("load error should be returned for keys without cached value")
+	}
+}
+
+func TestReadThroughNegativeCache(t *testing.T) {
+	var loads int32
+	loadFunc := func(ctx context.Context, key string) (string, error) {
+		atomic.AddInt32(&loads, 1)
+		return "", cache.ErrKeyNotFound
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithLoadFunc(loadFunc, time.Hour),
+		cache.WithNegativeTTL(10*time.Millisecond),
+	)
+	defer s.Stop()
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.Get("missing"); err != cache.ErrKeyNotFound {
+			t.Fatal("missing key should return key not found, got:", err)
+		}
+	}
+	if l := atomic.LoadInt32(&loads); l != 1 {
+		t.Fatal("missing key should be cached, loads:", l)
+	}
+
+	<-time.After(20 * time.Millisecond)
+	s.Get("missing")
+	if l := atomic.LoadInt32(&loads); l != 2 {
+		t.Fatal("cached missing key should expire, loads:", l)
+	}
+}
+
+func TestReadThroughDeduplicatesLoads(t *testing.T) {
+	var loads int32
+	release := make(chan struct{})
+	loadFunc := func(ctx context.Context, key string) (string, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "value", nil
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithLoadFunc(loadFunc, time.Hour),
+	)
+	defer s.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			item, err := s.Get("key")
+			if err != nil || item.Value() != "value" {
+				t.Error("concurrent Get should return loaded value")
+			}
+		}()
+	}
+
+	<-time.After(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if l := atomic.LoadInt32(&loads); l != 1 {
+		t.Fatal("concurrent loads of the same key should be deduplicated, loads:", l)
+	}
+}
@@ -0,0 +1,39 @@
+package cache
+
+import "log/slog"
+
+// Logger is a leveled structured logger used by sources and cache. Messages
+// are followed by alternating keys and values, e.g.
+// `logger.Info("source refreshed", "source", name, "keys", 10)`.
+//
+// *slog.Logger satisfies the interface and can be used directly.
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// SlogLogger returns a Logger that writes to provided slog logger. A nil
+// logger is replaced with slog.Default().
+func SlogLogger(l *slog.Logger) Logger {
+	if l == nil {
+		return slog.Default()
+	}
+	return l
+}
+
+// NopLogger returns a Logger that discards all messages
+func NopLogger() Logger {
+	return nopLogger{}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, keysAndValues ...interface{}) {}
+func (nopLogger) Info(msg string, keysAndValues ...interface{})  {}
+func (nopLogger) Warn(msg string, keysAndValues ...interface{})  {}
+func (nopLogger) Error(msg string, keysAndValues ...interface{}) {}
+
+var _ Logger = (*slog.Logger)(nil)
+var _ Logger = nopLogger{}
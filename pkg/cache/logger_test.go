@@ -0,0 +1,124 @@
+package cache_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+type logEntry struct {
+	level  string
+	msg    string
+	fields map[string]interface{}
+}
+
+type recordingLogger struct {
+	lock    sync.Mutex
+	entries []logEntry
+}
+
+func (l *recordingLogger) log(level string, msg string, keysAndValues []interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	fields := map[string]interface{}{}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		fields[keysAndValues[i].(string)] = keysAndValues[i+1]
+	}
+	l.entries = append(l.entries, logEntry{level, msg, fields})
+}
+
+func (l *recordingLogger) Debug(msg string, kv ...interface{}) { l.log("debug", msg, kv) }
+func (l *recordingLogger) Info(msg string, kv ...interface{})  { l.log("info", msg, kv) }
+func (l *recordingLogger) Warn(msg string, kv ...interface{})  { l.log("warn", msg, kv) }
+func (l *recordingLogger) Error(msg string, kv ...interface{}) { l.log("error", msg, kv) }
+
+func (l *recordingLogger) find(level string) (logEntry, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, e := range l.entries {
+		if e.level == level {
+			return e, true
+		}
+	}
+	return logEntry{}, false
+}
+
+func TestSourceLogger(t *testing.T) {
+	fetchErr := fmt.Errorf("error")
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		return nil, fetchErr
+	}
+
+	logger := &recordingLogger{}
+	done := make(chan struct{})
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+		cache.WithRetryWait(time.Millisecond),
+		cache.WithLogger(logger),
+		cache.WithRefreshHook(func(e cache.RefreshEvent) {
+			if e.Done {
+				close(done)
+			}
+		}),
+	)
+	defer s.Stop()
+	<-done
+
+	warn, ok := logger.find("warn")
+	if !ok {
+		t.Fatal("failed attempt should be logged as warning")
+	}
+	if warn.fields["source"] != "test" || warn.fields["attempt"] != 1 {
+		t.Fatal("warning should include source and attempt, got:", warn.fields)
+	}
+	if warn.fields["retry_in"] != time.Millisecond {
+		t.Fatal("warning should include retry wait, got:", warn.fields)
+	}
+
+	e, ok := logger.find("error")
+	if !ok {
+		t.Fatal("failed refresh should be logged as error")
+	}
+	if e.fields["error"] != fetchErr || e.fields["attempt"] != 3 {
+		t.Fatal("error should include fetch error and last attempt, got:", e.fields)
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := cache.SlogLogger(slog.New(slog.NewTextHandler(buf, nil)))
+	l.Info("source refreshed", "source", "test", "keys", 10)
+
+	out := buf.String()
+	if !strings.Contains(out, "source=test") || !strings.Contains(out, "keys=10") {
+		t.Fatal("slog logger should write structured fields, got:", out)
+	}
+
+	if cache.SlogLogger(nil) == nil {
+		t.Fatal("nil slog logger should fall back to default logger")
+	}
+}
+
+func TestCacheLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	c := cache.NewWithOptions(nil, cache.WithCacheLogger(logger))
+
+	if _, err := c.Get("missing", "key"); err != cache.ErrSourceNotFound {
+		t.Fatal("error should be source not found")
+	}
+
+	e, ok := logger.find("debug")
+	if !ok || e.fields["source"] != "missing" {
+		t.Fatal("missing source should be logged")
+	}
+}
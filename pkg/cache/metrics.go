@@ -0,0 +1,34 @@
+package cache
+
+import "time"
+
+// Metrics receives measurements from sources. Implementations must be safe
+// for concurrent use, Lookup is called on every Get.
+//
+// See the prometheus subpackage for an implementation that exposes metrics
+// in Prometheus text format.
+type Metrics interface {
+	// FetchAttempt is called after every fetch attempt with its duration and
+	// error, nil on success
+	FetchAttempt(source string, duration time.Duration, err error)
+
+	// SourceUpdated is called when the source data is replaced, including
+	// default data provided at construction
+	SourceUpdated(source string, keys int, lastRefreshed time.Time)
+
+	// Lookup is called on every Get with a flag whether the key was found
+	Lookup(source string, hit bool)
+}
+
+// NopMetrics returns Metrics that discard all measurements
+func NopMetrics() Metrics {
+	return nopMetrics{}
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) FetchAttempt(source string, duration time.Duration, err error)  {}
+func (nopMetrics) SourceUpdated(source string, keys int, lastRefreshed time.Time) {}
+func (nopMetrics) Lookup(source string, hit bool)                                 {}
+
+var _ Metrics = nopMetrics{}
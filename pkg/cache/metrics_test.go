@@ -0,0 +1,75 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+type recordingMetrics struct {
+	lock     sync.Mutex
+	attempts int
+	keys     int
+	hits     int
+	misses   int
+}
+
+func (m *recordingMetrics) FetchAttempt(source string, duration time.Duration, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.attempts++
+}
+
+func (m *recordingMetrics) SourceUpdated(source string, keys int, lastRefreshed time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.keys = keys
+}
+
+func (m *recordingMetrics) Lookup(source string, hit bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if hit {
+		m.hits++
+	} else {
+		m.misses++
+	}
+}
+
+func TestSourceMetrics(t *testing.T) {
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		return map[string]string{"key": "value", "key2": "value2"}, nil
+	}
+
+	m := &recordingMetrics{}
+	done := make(chan struct{})
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+		cache.WithMetrics(m),
+		cache.WithRefreshHook(func(e cache.RefreshEvent) {
+			close(done)
+		}),
+	)
+	defer s.Stop()
+	<-done
+
+	s.Get("key")
+	s.Get("missing")
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.attempts != 1 {
+		t.Fatal("fetch attempt should be recorded")
+	}
+	if m.keys != 2 {
+		t.Fatal("key count should be recorded")
+	}
+	if m.hits != 1 || m.misses != 1 {
+		t.Fatal("hits and misses should be recorded")
+	}
+}
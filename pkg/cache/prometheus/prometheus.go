@@ -0,0 +1,296 @@
+// Package prometheus implements cache.Metrics and exposes collected metrics
+// in Prometheus text exposition format without depending on the Prometheus
+// client library.
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+// DefaultBuckets are fetch duration histogram buckets in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics collects per source measurements. It's safe for concurrent use and
+// a single instance can be shared by many sources.
+type Metrics struct {
+	namespace string
+	buckets   []float64
+
+	// sources maps source names to *sourceMetrics, it's read without
+	// locking on every Get
+	sources sync.Map
+}
+
+// sourceMetrics are measurements of a single source. Counters are updated
+// atomically, so lookups don't contend on a lock.
+type sourceMetrics struct {
+	attempts  atomic.Uint64
+	successes atomic.Uint64
+	failures  atomic.Uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+
+	// Histogram and data state guarded by lock
+	lock           sync.Mutex
+	durationCounts []uint64
+	durationCount  uint64
+	durationSum    float64
+	keys           int
+	lastRefreshed  time.Time
+}
+
+// values is a point in time copy of sourceMetrics
+type values struct {
+	attempts  uint64
+	successes uint64
+	failures  uint64
+
+	durationCounts []uint64
+	durationCount  uint64
+	durationSum    float64
+
+	keys          int
+	lastRefreshed time.Time
+
+	hits   uint64
+	misses uint64
+}
+
+// Option is a function that sets an option for Metrics
+type Option func(*Metrics)
+
+// WithNamespace overrides default `cache` metric name prefix
+func WithNamespace(namespace string) Option {
+	return func(m *Metrics) {
+		m.namespace = namespace
+	}
+}
+
+// WithBuckets overrides DefaultBuckets of the fetch duration histogram. The
+// buckets must be sorted in increasing order.
+func WithBuckets(buckets []float64) Option {
+	return func(m *Metrics) {
+		m.buckets = buckets
+	}
+}
+
+// New creates a metrics collector that can be passed to sources using
+// cache.WithMetrics
+func New(opts ...Option) *Metrics {
+	m := &Metrics{
+		namespace: "cache",
+		buckets:   DefaultBuckets,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+func (m *Metrics) source(name string) *sourceMetrics {
+	if sm, ok := m.sources.Load(name); ok {
+		return sm.(*sourceMetrics)
+	}
+
+	sm, _ := m.sources.LoadOrStore(name, &sourceMetrics{
+		durationCounts: make([]uint64, len(m.buckets)),
+	})
+	return sm.(*sourceMetrics)
+}
+
+// values returns a copy of the measurements
+func (sm *sourceMetrics) values() values {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	return values{
+		attempts:       sm.attempts.Load(),
+		successes:      sm.successes.Load(),
+		failures:       sm.failures.Load(),
+		durationCounts: append([]uint64(nil), sm.durationCounts...),
+		durationCount:  sm.durationCount,
+		durationSum:    sm.durationSum,
+		keys:           sm.keys,
+		lastRefreshed:  sm.lastRefreshed,
+		hits:           sm.hits.Load(),
+		misses:         sm.misses.Load(),
+	}
+}
+
+// FetchAttempt records a fetch attempt, its duration and outcome
+func (m *Metrics) FetchAttempt(source string, duration time.Duration, err error) {
+	sm := m.source(source)
+	seconds := duration.Seconds()
+
+	sm.attempts.Add(1)
+	if err != nil {
+		sm.failures.Add(1)
+	} else {
+		sm.successes.Add(1)
+	}
+
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	for i, b := range m.buckets {
+		if seconds <= b {
+			sm.durationCounts[i]++
+		}
+	}
+	sm.durationCount++
+	sm.durationSum += seconds
+}
+
+// SourceUpdated records number of keys and refresh time of source data
+func (m *Metrics) SourceUpdated(source string, keys int, lastRefreshed time.Time) {
+	sm := m.source(source)
+
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	sm.keys = keys
+	sm.lastRefreshed = lastRefreshed
+}
+
+// Lookup records a cache hit or miss
+func (m *Metrics) Lookup(source string, hit bool) {
+	sm := m.source(source)
+	if hit {
+		sm.hits.Add(1)
+	} else {
+		sm.misses.Add(1)
+	}
+}
+
+// WriteTo writes all metrics in Prometheus text format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var names []string
+	snapshot := map[string]values{}
+	m.sources.Range(func(name, sm interface{}) bool {
+		names = append(names, name.(string))
+		snapshot[name.(string)] = sm.(*sourceMetrics).values()
+		return true
+	})
+	sort.Strings(names)
+
+	now := time.Now()
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+
+	counter := func(name, help string, value func(values) uint64) {
+		m.header(cw, name, help, "counter")
+		for _, n := range names {
+			fmt.Fprintf(cw, "%s{source=%s} %d\n", m.name(name), quote(n), value(snapshot[n]))
+		}
+	}
+
+	counter("refresh_attempts_total", "Total number of fetch attempts.", func(s values) uint64 { return s.attempts })
+	counter("refresh_successes_total", "Total number of successful fetch attempts.", func(s values) uint64 { return s.successes })
+	counter("refresh_failures_total", "Total number of failed fetch attempts.", func(s values) uint64 { return s.failures })
+
+	name := "fetch_duration_seconds"
+	m.header(cw, name, "Duration of fetch attempts.", "histogram")
+	for _, n := range names {
+		s := snapshot[n]
+		for i, b := range m.buckets {
+			fmt.Fprintf(cw, "%s_bucket{source=%s,le=%s} %d\n", m.name(name), quote(n), quote(formatFloat(b)), s.durationCounts[i])
+		}
+		fmt.Fprintf(cw, "%s_bucket{source=%s,le=\"+Inf\"} %d\n", m.name(name), quote(n), s.durationCount)
+		fmt.Fprintf(cw, "%s_sum{source=%s} %s\n", m.name(name), quote(n), formatFloat(s.durationSum))
+		fmt.Fprintf(cw, "%s_count{source=%s} %d\n", m.name(name), quote(n), s.durationCount)
+	}
+
+	name = "keys"
+	m.header(cw, name, "Number of keys served by the source.", "gauge")
+	for _, n := range names {
+		fmt.Fprintf(cw, "%s{source=%s} %d\n", m.name(name), quote(n), snapshot[n].keys)
+	}
+
+	name = "data_age_seconds"
+	m.header(cw, name, "Time since the source data was last refreshed.", "gauge")
+	for _, n := range names {
+		s := snapshot[n]
+		if s.lastRefreshed.IsZero() {
+			continue
+		}
+		fmt.Fprintf(cw, "%s{source=%s} %s\n", m.name(name), quote(n), formatFloat(now.Sub(s.lastRefreshed).Seconds()))
+	}
+
+	name = "lookups_total"
+	m.header(cw, name, "Total number of Get calls by result.", "counter")
+	for _, n := range names {
+		s := snapshot[n]
+		fmt.Fprintf(cw, "%s{source=%s,result=\"hit\"} %d\n", m.name(name), quote(n), s.hits)
+		fmt.Fprintf(cw, "%s{source=%s,result=\"miss\"} %d\n", m.name(name), quote(n), s.misses)
+	}
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+	return cw.n, cw.w.Flush()
+}
+
+// ServeHTTP exposes metrics in Prometheus text format, so Metrics can be
+// registered directly as an http.Handler
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+func (m *Metrics) name(name string) string {
+	if m.namespace == "" {
+		return name
+	}
+	return m.namespace + "_" + name
+}
+
+func (m *Metrics) header(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name(name), help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name(name), typ)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func quote(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+var _ cache.Metrics = (*Metrics)(nil)
+var _ http.Handler = (*Metrics)(nil)
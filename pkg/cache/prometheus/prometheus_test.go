@@ -0,0 +1,106 @@
+package prometheus_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+	"github.com/mhrabovcin/cache/pkg/cache/prometheus"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	m := prometheus.New(prometheus.WithBuckets([]float64{0.1, 1}))
+
+	m.FetchAttempt("db", 50*time.Millisecond, nil)
+	m.FetchAttempt("db", 500*time.Millisecond, fmt.Errorf("error"))
+	m.SourceUpdated("db", 3, time.Now().Add(-1*time.Minute))
+	m.Lookup("db", true)
+	m.Lookup("db", true)
+	m.Lookup("db", false)
+	m.SourceUpdated(`quoted "name"`, 0, cache.Never)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatal("wrong content type:", ct)
+	}
+
+	body := rec.Body.String()
+	expected := []string{
+		"# TYPE cache_refresh_attempts_total counter",
+		`cache_refresh_attempts_total{source="db"} 2`,
+		`cache_refresh_successes_total{source="db"} 1`,
+		`cache_refresh_failures_total{source="db"} 1`,
+		"# TYPE cache_fetch_duration_seconds histogram",
+		`cache_fetch_duration_seconds_bucket{source="db",le="0.1"} 1`,
+		`cache_fetch_duration_seconds_bucket{source="db",le="1"} 2`,
+		`cache_fetch_duration_seconds_bucket{source="db",le="+Inf"} 2`,
+		`cache_fetch_duration_seconds_sum{source="db"} 0.55`,
+		`cache_fetch_duration_seconds_count{source="db"} 2`,
+		`cache_keys{source="db"} 3`,
+		`cache_data_age_seconds{source="db"} 60`,
+		`cache_lookups_total{source="db",result="hit"} 2`,
+		`cache_lookups_total{source="db",result="miss"} 1`,
+		`cache_keys{source="quoted \"name\""} 0`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(body, e) {
+			t.Fatalf("metrics should contain %q, got:\n%s", e, body)
+		}
+	}
+
+	if strings.Contains(body, `cache_data_age_seconds{source="quoted`) {
+		t.Fatal("data age shouldn't be reported for never refreshed source")
+	}
+}
+
+func TestMetricsNamespace(t *testing.T) {
+	m := prometheus.New(prometheus.WithNamespace("app_cache"))
+	m.Lookup("s", true)
+
+	b := &strings.Builder{}
+	if _, err := m.WriteTo(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(b.String(), `app_cache_lookups_total{source="s",result="hit"} 1`) {
+		t.Fatal("metrics should use custom namespace, got:", b.String())
+	}
+}
+
+func TestMetricsConcurrentLookups(t *testing.T) {
+	m := prometheus.New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(source string) {
+			defer wg.Done()
+			for n := 0; n < 1000; n++ {
+				m.Lookup(source, n%4 != 0)
+			}
+			m.FetchAttempt(source, time.Millisecond, nil)
+		}(fmt.Sprint("s", i%2))
+	}
+	wg.Wait()
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := rec.Body.String()
+	for _, e := range []string{
+		`cache_lookups_total{source="s0",result="hit"} 3000`,
+		`cache_lookups_total{source="s0",result="miss"} 1000`,
+		`cache_lookups_total{source="s1",result="hit"} 3000`,
+		`cache_refresh_attempts_total{source="s1"} 4`,
+	} {
+		if !strings.Contains(body, e) {
+			t.Fatalf("metrics should contain %q, got:\n%s", e, body)
+		}
+	}
+}
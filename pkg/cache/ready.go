@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+)
+
+// readySource is implemented by sources that load data asynchronously
+type readySource interface {
+	WaitReady(ctx context.Context) error
+}
+
+// markReady is called once the source has data to serve
+func (s *source) markReady() {
+	s.readyOnce.Do(func() {
+		close(s.readyCh)
+	})
+}
+
+// WaitReady blocks until the source has data to serve. Sources with default
+// data or without a fetch function are ready immediately, other sources
+// become ready after the first successful fetch. An error is returned if ctx
+// is done first or the source is stopped before it became ready. It doesn't
+// wait for the change notification of the first fetch, which may be
+// delivered after WaitReady returns.
+func (s *source) WaitReady(ctx context.Context) error {
+	select {
+	case <-s.readyCh:
+		return nil
+	default:
+	}
+
+	select {
+	case <-s.readyCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.ctx.Done():
+		return s.notReadyErr()
+	}
+}
+
+func (s *source) notReadyErr() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.lastError != nil {
+		return fmt.Errorf("%w: %v", ErrNotReady, s.lastError)
+	}
+	return ErrNotReady
+}
+
+// waitInitialLoad blocks until the source is ready or ctx is done, in which
+// case the source is stopped and the not ready error is returned.
+func (s *source) waitInitialLoad(ctx context.Context) error {
+	if err := s.WaitReady(ctx); err != nil {
+		err = s.notReadyErr()
+		s.logger.Error(
+			"initial load failed, stopping source",
+			"source", s.name,
+			"error", err,
+		)
+		s.Stop()
+		return err
+	}
+	return nil
+}
+
+// WaitReady blocks until named sources are ready to serve data, or all
+// sources if no names are provided.
+func (c *cacheImpl) WaitReady(ctx context.Context, names ...string) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+
+	if len(names) == 0 {
+		names = c.Sources()
+	}
+
+	for _, name := range names {
+		s, err := c.Source(name)
+		if err != nil {
+			return fmt.Errorf("source %s: %w", name, err)
+		}
+
+		rs, ok := s.(readySource)
+		if !ok {
+			continue
+		}
+
+		if err := rs.WaitReady(ctx); err != nil {
+			return fmt.Errorf("source %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
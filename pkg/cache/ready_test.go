@@ -0,0 +1,175 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+func TestWaitReady(t *testing.T) {
+	release := make(chan struct{})
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return map[string]string{"key": "value"}, nil
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+	)
+	defer s.Stop()
+
+	if _, err := s.Get("key"); err != cache.ErrNotReady {
+		t.Fatal("Get should return not ready error during initial load, got:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.WaitReady(ctx); err != context.DeadlineExceeded {
+		t.Fatal("WaitReady should respect context deadline, got:", err)
+	}
+
+	close(release)
+	if err := s.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := s.Get("key")
+	if err != nil || item.Value() != "value" {
+		t.Fatal("ready source should serve data")
+	}
+}
+
+func TestWaitReadyWithDefaultData(t *testing.T) {
+	s := cache.NewSource(
+		"test",
+		cache.WithDefaultData(map[string]string{"key": "value"}),
+		cache.WithFetchFunc(func(ctx context.Context) (map[string]string, error) {
+			return nil, fmt.Errorf("error")
+		}, time.Hour),
+	)
+	defer s.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.WaitReady(ctx); err != nil {
+		t.Fatal("source with default data should be ready immediately")
+	}
+}
+
+func TestInitialLoadTimeout(t *testing.T) {
+	fetchErr := fmt.Errorf("connection refused")
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(func(ctx context.Context) (map[string]string, error) {
+			return nil, fetchErr
+		}, time.Hour),
+		cache.WithRetryWait(time.Millisecond),
+		cache.WithInitialLoadTimeout(20*time.Millisecond),
+	)
+
+	err := s.WaitReady(context.Background())
+	if !errors.Is(err, cache.ErrNotReady) {
+		t.Fatal("failed initial load should return not ready error, got:", err)
+	}
+	if err.Error() != "Source is not ready: connection refused" {
+		t.Fatal("error should include last fetch error, got:", err)
+	}
+
+	if _, err := s.Get("key"); err != cache.ErrNotReady {
+		t.Fatal("Get should return not ready error, got:", err)
+	}
+}
+
+func TestNewSourceE(t *testing.T) {
+	s, err := cache.NewSourceE(
+		"test",
+		cache.WithFetchFunc(func(ctx context.Context) (map[string]string, error) {
+			return nil, fmt.Errorf("connection refused")
+		}, time.Hour),
+		cache.WithRetryWait(time.Millisecond),
+		cache.WithInitialLoadTimeout(20*time.Millisecond),
+		cache.WithLogger(cache.NopLogger()),
+	)
+	if s != nil || !errors.Is(err, cache.ErrNotReady) {
+		t.Fatal("failed initial load should return an error, got:", err)
+	}
+	if err.Error() != "source test: Source is not ready: connection refused" {
+		t.Fatal("error should include source and last fetch error, got:", err)
+	}
+
+	s, err = cache.NewSourceE(
+		"test",
+		cache.WithFetchFunc(func(ctx context.Context) (map[string]string, error) {
+			return map[string]string{"key": "value"}, nil
+		}, time.Hour),
+		cache.WithInitialLoadTimeout(time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	if item, err := s.Get("key"); err != nil || item.Value() != "value" {
+		t.Fatal("source should be loaded after construction")
+	}
+}
+
+func TestInitialLoadTimeoutSuccess(t *testing.T) {
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(func(ctx context.Context) (map[string]string, error) {
+			return map[string]string{"key": "value"}, nil
+		}, time.Hour),
+		cache.WithInitialLoadTimeout(time.Second),
+	)
+	defer s.Stop()
+
+	// Construction blocks until the data is loaded
+	item, err := s.Get("key")
+	if err != nil || item.Value() != "value" {
+		t.Fatal("source should be loaded after construction")
+	}
+}
+
+func TestCacheWaitReady(t *testing.T) {
+	release := make(chan struct{})
+	s1 := cache.NewSource(
+		"s1",
+		cache.WithFetchFunc(func(ctx context.Context) (map[string]string, error) {
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+			return map[string]string{"key": "value"}, nil
+		}, time.Hour),
+	)
+	defer s1.Stop()
+	s2 := cache.NewStaticSource("s2", map[string]string{"key": "value"}, time.Now())
+
+	c := cache.New(s1, s2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.WaitReady(ctx, "s2"); err != nil {
+		t.Fatal("static source should be ready")
+	}
+	if err := c.WaitReady(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("loading source should block WaitReady, got:", err)
+	}
+	if err := c.WaitReady(ctx, "missing"); !errors.Is(err, cache.ErrSourceNotFound) {
+		t.Fatal("unknown source should return not found error, got:", err)
+	}
+
+	close(release)
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -7,7 +7,10 @@ import (
 )
 
 // NewRedisSource initializes a cache source that fetches data from redis
-// provided hashKey using HGETALL command
+// provided hashKey using HGETALL command. Hash field values are binary safe
+// and can be accessed using Item.ValueBytes(). Stop and WithFetchTimeout stop
+// waiting for a command but can't interrupt it, set ReadTimeout in redisOpts
+// to bound it.
 // See: https://redis.io/commands/hgetall
 func NewRedisSource(
 	name string,
@@ -16,19 +19,39 @@ func NewRedisSource(
 	frequency time.Duration,
 	opts ...Option,
 ) StoppableSource {
-	opts = append(opts, WithFetchFunc(
+	return NewSource(name, redisSourceOptions(hashKey, redisOpts, frequency, opts)...)
+}
+
+// NewRedisSourceE is like NewRedisSource but returns an error if the initial
+// load set with WithInitialLoadTimeout fails
+func NewRedisSourceE(
+	name string,
+	hashKey string,
+	redisOpts *redis.Options,
+	frequency time.Duration,
+	opts ...Option,
+) (StoppableSource, error) {
+	return NewSourceE(name, redisSourceOptions(hashKey, redisOpts, frequency, opts)...)
+}
+
+func redisSourceOptions(
+	hashKey string,
+	redisOpts *redis.Options,
+	frequency time.Duration,
+	opts []Option,
+) []Option {
+	return append(opts, WithFetchFunc(
 		redisFetchFunc(hashKey, redisOpts),
 		frequency,
 	))
-	return NewSource(
-		name,
-		opts...,
-	)
 }
 
-func redisFetchFunc(hashKey string, opts *redis.Options) func() (map[string]string, error) {
+func redisFetchFunc(hashKey string, opts *redis.Options) FetchFunc {
 	c := redis.NewClient(opts)
-	return func() (map[string]string, error) {
+
+	// The client ignores contexts, so a hung command keeps running until the
+	// client read timeout and the source only stops waiting for it
+	return WrapFetchFunc(func() (map[string]string, error) {
 		return c.HGetAll(hashKey).Result()
-	}
+	})
 }
@@ -2,8 +2,10 @@ package cache_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"testing"
 	"time"
@@ -96,7 +98,10 @@ func TestRedisSource(t *testing.T) {
 		100*time.Millisecond,
 	)
 	defer s.Stop()
-	<-time.After(200 * time.Millisecond)
+
+	if err := s.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
 
 	item, err := s.Get("key")
 	if err != nil {
@@ -136,3 +141,48 @@ func TestRedisSource(t *testing.T) {
 		t.Fatal("wrong value was returned for `new-key`")
 	}
 }
+
+func TestRedisSourceFetchTimeout(t *testing.T) {
+	// Server accepting connections without ever replying
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	s := cache.NewRedisSource(
+		"test",
+		"test",
+		&redis.Options{Addr: l.Addr().String(), ReadTimeout: time.Minute},
+		time.Hour,
+		cache.WithFetchTimeout(50*time.Millisecond),
+		cache.WithRetryPolicy(cache.NoRetry()),
+		cache.WithLogger(cache.NopLogger()),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Refresh(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("hung fetch should time out:", err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stop should interrupt a hung fetch")
+	}
+}
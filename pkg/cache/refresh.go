@@ -0,0 +1,309 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// refreshCall is a single refresh shared by all callers that requested it
+// while it was running
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+// coalescedRefresh starts a refresh unless one is already running, in which
+// case the running one is returned. Scheduled refreshes run in the calling
+// goroutine, manual ones in a separate goroutine so callers can give up
+// waiting without cancelling the fetch for others. Manual refreshes of
+// sources using a scheduler are run by the scheduler, so they count towards
+// its worker and backend limits.
+func (s *source) coalescedRefresh(manual bool) *refreshCall {
+	s.refreshLock.Lock()
+	if c := s.inflight; c != nil {
+		s.refreshLock.Unlock()
+		return c
+	}
+
+	c := &refreshCall{done: make(chan struct{})}
+
+	// Stop cancels the context under the lock, so no refresh can start
+	// after Stop began waiting for running ones
+	if s.ctx.Err() != nil {
+		s.refreshLock.Unlock()
+		c.err = ErrStopped
+		close(c.done)
+		return c
+	}
+
+	s.inflight = c
+	if manual {
+		s.manualRefreshes.Add(1)
+	}
+	s.refreshLock.Unlock()
+
+	run := func() {
+		c.err = s.refresh()
+
+		s.refreshLock.Lock()
+		s.inflight = nil
+		s.refreshLock.Unlock()
+		close(c.done)
+
+		if manual {
+			s.reschedule()
+		}
+	}
+
+	if !manual {
+		run()
+		return c
+	}
+
+	runManual := func() {
+		defer s.manualRefreshes.Done()
+		run()
+	}
+	if s.job != nil && s.scheduler.runOnce(s.job, runManual) {
+		return c
+	}
+
+	go runManual()
+	return c
+}
+
+// reschedule moves the next scheduled refresh a full period after a manual
+// refresh
+func (s *source) reschedule() {
+	if s.job != nil {
+		if at, ok := s.job.next(time.Now()); ok {
+			s.scheduler.reschedule(s.job, at)
+		}
+		return
+	}
+
+	select {
+	case s.rescheduleCh <- struct{}{}:
+	default:
+	}
+}
+
+// schedule registers periodic refreshes with the scheduler. It mirrors start
+// for sources that don't run their own goroutine.
+func (s *source) schedule(backend string) {
+	if s.fetchFunc == nil {
+		return
+	}
+
+	s.job = &scheduledJob{
+		name:    s.name,
+		backend: backend,
+		index:   -1,
+		// A scheduled refresh runs in this goroutine. A refresh already
+		// running is a manual one that may be waiting for a worker, so
+		// it isn't waited for.
+		run: func() {
+			s.coalescedRefresh(false)
+		},
+		next: func(now time.Time) (time.Time, bool) {
+			at := s.plannedRefresh(now)
+			return at, !at.Equal(Never)
+		},
+	}
+
+	if !s.snapshot().ready {
+		s.logger.Info("no data provided, initial fetch", "source", s.name)
+	}
+
+	// Never is the zero time, it would be due immediately
+	at := s.firstRefresh(time.Now())
+	if at.Equal(Never) {
+		return
+	}
+	s.scheduler.add(s.job, at)
+}
+
+// firstRefresh returns the time of the first scheduled refresh. A source
+// without data fetches immediately and snapshot data keeps its age, so it's
+// refreshed when it would have been due.
+func (s *source) firstRefresh(now time.Time) time.Time {
+	snap := s.snapshot()
+	at := now
+	if snap.ready {
+		from := now
+		if s.snapshotLoaded {
+			from = snap.lastRefresh
+		}
+		if at = s.plannedRefresh(from); at.Equal(Never) {
+			return Never
+		}
+	}
+	return at.Add(randomDelay(s.initialDelay))
+}
+
+// plannedRefresh returns the time of the scheduled refresh following one at
+// t, including random jitter, or Never
+func (s *source) plannedRefresh(t time.Time) time.Time {
+	at := s.nextRefreshAfter(t)
+	if at.Equal(Never) {
+		return Never
+	}
+	return at.Add(randomDelay(s.refreshJitter))
+}
+
+// nextRefreshAfter returns the time data refreshed at t is due according to
+// the schedule, or Never
+func (s *source) nextRefreshAfter(t time.Time) time.Time {
+	return s.refreshSchedule.Next(t)
+}
+
+// dataChanged returns true if a refresh changed data, it's only compared
+// for adaptive schedules
+func (s *source) dataChanged(old, new map[string]string) bool {
+	if _, ok := s.refreshSchedule.(adaptiveSchedule); !ok {
+		return false
+	}
+	return !maps.Equal(old, new)
+}
+
+// adaptSchedule reports the result of a refresh to an adaptive schedule, so
+// the following refresh is scheduled according to it
+func (s *source) adaptSchedule(changed bool, err error) {
+	a, ok := s.refreshSchedule.(adaptiveSchedule)
+	if !ok {
+		return
+	}
+
+	interval := s.refreshInterval()
+	a.RefreshDone(changed, err)
+
+	if i := s.refreshInterval(); i != interval {
+		s.logger.Debug("refresh interval changed", "source", s.name, "interval", i)
+	}
+}
+
+// refreshInterval returns the current interval of the schedule, zero if it
+// isn't known
+func (s *source) refreshInterval() time.Duration {
+	if i, ok := s.refreshSchedule.(intervalSchedule); ok {
+		return i.Interval()
+	}
+	return 0
+}
+
+// newRefreshSchedule returns the schedule set by options, a schedule built
+// from the refresh frequency by default
+func newRefreshSchedule(o *Options) Schedule {
+	switch {
+	case o.Schedule != nil:
+		return o.Schedule
+	case o.AlignRefresh:
+		return Aligned(o.RefreshFrequency, o.AlignOffset)
+	default:
+		return Every(o.RefreshFrequency)
+	}
+}
+
+// randomDelay returns a random duration between zero and max
+func randomDelay(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max) + 1))
+}
+
+// Refresh fetches new data immediately and waits for the result. Concurrent
+// calls are coalesced into a single fetch, including a scheduled refresh that
+// is already running. Cancelling ctx stops waiting but doesn't cancel the
+// fetch. The next scheduled refresh is moved a full refresh period after a
+// manual refresh.
+func (s *source) Refresh(ctx context.Context) error {
+	if s.fetchFunc == nil {
+		return nil
+	}
+
+	c := s.coalescedRefresh(true)
+
+	select {
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// revalidateStale starts a background refresh if data is stale or within the
+// refresh-ahead window and no attempt was made since it entered the window
+func (s *source) revalidateStale() {
+	if s.fetchFunc == nil {
+		return
+	}
+
+	// Data without refresh time has no known refresh schedule
+	snap := s.snapshot()
+	if snap.lastRefresh.IsZero() || snap.nextRefresh.Equal(Never) {
+		return
+	}
+
+	window := snap.nextRefresh.Add(-s.revalidateAhead)
+	if time.Now().Before(window) {
+		return
+	}
+
+	s.lock.RLock()
+	attempted := s.lastAttempt.After(window)
+	s.lock.RUnlock()
+
+	if attempted {
+		return
+	}
+
+	s.logger.Debug("revalidating stale data", "source", s.name)
+	s.coalescedRefresh(true)
+}
+
+// refresher is implemented by sources that support manual refresh
+type refresher interface {
+	Refresh(ctx context.Context) error
+}
+
+// RefreshAll refreshes all sources that support manual refresh concurrently
+// and waits for the results. Errors of failed sources are joined together.
+func (c *cacheImpl) RefreshAll(ctx context.Context) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+
+	var wg sync.WaitGroup
+	sources := c.all()
+	errCh := make(chan error, len(sources))
+
+	for name, s := range sources {
+		r, ok := s.(refresher)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(name string, r refresher) {
+			defer wg.Done()
+			if err := r.Refresh(ctx); err != nil {
+				errCh <- fmt.Errorf("source %s: %w", name, err)
+			}
+		}(name, r)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	var errs []error
+	for err := range errCh {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
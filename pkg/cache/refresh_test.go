@@ -0,0 +1,386 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+func TestManualRefresh(t *testing.T) {
+	var version int32
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		v := atomic.AddInt32(&version, 1)
+		return map[string]string{"key": fmt.Sprint(v)}, nil
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithDefaultData(map[string]string{"key": "0"}),
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+	)
+	defer s.Stop()
+
+	if err := s.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := s.Get("key")
+	if err != nil || item.Value() != "1" {
+		t.Fatal("manual refresh should fetch new data")
+	}
+}
+
+func TestManualRefreshError(t *testing.T) {
+	fetchErr := fmt.Errorf("error")
+	s := cache.NewSource(
+		"test",
+		cache.WithDefaultData(map[string]string{"key": "0"}),
+		cache.WithFetchFunc(func(ctx context.Context) (map[string]string, error) {
+			return nil, fetchErr
+		}, time.Hour),
+		cache.WithRetryPolicy(cache.NoRetry()),
+	)
+
+	if err := s.Refresh(context.Background()); err != fetchErr {
+		t.Fatal("refresh should return fetch error, got:", err)
+	}
+
+	s.Stop()
+	if err := s.Refresh(context.Background()); err != cache.ErrStopped {
+		t.Fatal("refresh of stopped source should fail, got:", err)
+	}
+}
+
+func TestManualRefreshCoalescing(t *testing.T) {
+	var fetches int32
+	release := make(chan struct{})
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return map[string]string{"key": "value"}, nil
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithDefaultData(map[string]string{"key": "0"}),
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+	)
+	defer s.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.Refresh(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	// Caller giving up doesn't cancel the shared fetch
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Refresh(ctx); err != context.DeadlineExceeded {
+		t.Fatal("refresh should respect caller context, got:", err)
+	}
+
+	close(release)
+	wg.Wait()
+
+	if f := atomic.LoadInt32(&fetches); f != 1 {
+		t.Fatal("concurrent refreshes should share a single fetch, got:", f)
+	}
+}
+
+func TestManualRefreshReschedules(t *testing.T) {
+	var fetches int32
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		atomic.AddInt32(&fetches, 1)
+		return map[string]string{"key": "value"}, nil
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithDefaultData(map[string]string{"key": "0"}),
+		cache.WithFetchFunc(fetchFunc, 100*time.Millisecond),
+	)
+	defer s.Stop()
+
+	<-time.After(60 * time.Millisecond)
+	if err := s.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Scheduled refresh would have run at 100ms without the manual refresh
+	<-time.After(70 * time.Millisecond)
+	if f := atomic.LoadInt32(&fetches); f != 1 {
+		t.Fatal("scheduled refresh should be moved after manual refresh, fetches:", f)
+	}
+}
+
+func TestCacheRefreshAll(t *testing.T) {
+	fetchErr := fmt.Errorf("error")
+	s1 := cache.NewSource(
+		"s1",
+		cache.WithDefaultData(map[string]string{"key": "0"}),
+		cache.WithFetchFunc(func(ctx context.Context) (map[string]string, error) {
+			return map[string]string{"key": "1"}, nil
+		}, time.Hour),
+	)
+	defer s1.Stop()
+	s2 := cache.NewSource(
+		"s2",
+		cache.WithDefaultData(map[string]string{"key": "0"}),
+		cache.WithFetchFunc(func(ctx context.Context) (map[string]string, error) {
+			return nil, fetchErr
+		}, time.Hour),
+		cache.WithRetryPolicy(cache.NoRetry()),
+	)
+	defer s2.Stop()
+	s3 := cache.NewStaticSource("s3", map[string]string{"key": "0"}, time.Now())
+
+	c := cache.New(s1, s2, s3)
+
+	err := c.RefreshAll(context.Background())
+	if !errors.Is(err, fetchErr) {
+		t.Fatal("failed source error should be returned, got:", err)
+	}
+	if err.Error() != "source s2: error" {
+		t.Fatal("error should include source name, got:", err)
+	}
+
+	item, _ := c.Get("s1", "key")
+	if item.Value() != "1" {
+		t.Fatal("successful source should be refreshed")
+	}
+}
+
+// eventually polls cond until it's true or fails the test after a second
+func eventually(t *testing.T, msg string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		<-time.After(5 * time.Millisecond)
+	}
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	// Revalidation is blocked until the current data was checked
+	var fetches int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		v := atomic.AddInt32(&fetches, 1)
+		if v > 1 {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		return map[string]string{"key": fmt.Sprint(v)}, nil
+	}
+
+	// Data enters the refresh-ahead window 200ms after refresh
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+		cache.WithStaleWhileRevalidate(time.Hour-200*time.Millisecond),
+	)
+	defer s.Stop()
+
+	if err := s.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := s.Get("key")
+	if err != nil || item.Value() != "1" {
+		t.Fatal("initial data should be served")
+	}
+
+	<-time.After(250 * time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		item, err := s.Get("key")
+		if err != nil || item.Value() != "1" {
+			t.Fatal("current data should be returned while revalidating")
+		}
+	}
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("get within refresh-ahead window should start a refresh")
+	}
+	close(release)
+
+	eventually(t, "get within refresh-ahead window should trigger refresh", func() bool {
+		item, _ := s.Get("key")
+		return item.Value() == "2"
+	})
+
+	s.Get("key")
+	<-time.After(20 * time.Millisecond)
+	if f := atomic.LoadInt32(&fetches); f != 2 {
+		t.Fatal("single refresh should be triggered, fetches:", f)
+	}
+}
+
+func TestStaleWhileRevalidateFailure(t *testing.T) {
+	var fetches int32
+	var fail int32
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		atomic.AddInt32(&fetches, 1)
+		if atomic.LoadInt32(&fail) == 1 {
+			return nil, fmt.Errorf("error")
+		}
+		return map[string]string{"key": "value"}, nil
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+		cache.WithRetryPolicy(cache.NoRetry()),
+		cache.WithLogger(cache.NopLogger()),
+		cache.WithStaleWhileRevalidate(time.Hour-200*time.Millisecond),
+	)
+	defer s.Stop()
+
+	if err := s.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	<-time.After(250 * time.Millisecond)
+	atomic.StoreInt32(&fail, 1)
+
+	item, err := s.Get("key")
+	if err != nil || item.Value() != "value" {
+		t.Fatal("current data should be returned")
+	}
+
+	eventually(t, "data should be revalidated", func() bool {
+		return s.Status().ConsecutiveFailures == 1
+	})
+
+	for i := 0; i < 5; i++ {
+		s.Get("key")
+	}
+	<-time.After(20 * time.Millisecond)
+	if f := atomic.LoadInt32(&fetches); f != 2 {
+		t.Fatal("failed revalidation shouldn't be retried on every get, fetches:", f)
+	}
+}
+
+func TestAlignedRefresh(t *testing.T) {
+	// Failing fetch keeps default data and its refresh time
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		return nil, errors.New("error")
+	}
+	lastRefresh := time.Date(2020, 1, 1, 10, 0, 25, 0, time.UTC)
+
+	for _, tc := range []struct {
+		offset time.Duration
+		next   time.Time
+	}{
+		{0, time.Date(2020, 1, 1, 10, 1, 0, 0, time.UTC)},
+		{15 * time.Second, time.Date(2020, 1, 1, 10, 1, 15, 0, time.UTC)},
+		{30 * time.Second, time.Date(2020, 1, 1, 10, 0, 30, 0, time.UTC)},
+	} {
+		s := cache.NewSource(
+			"test",
+			cache.WithDefaultData(map[string]string{"key": "value"}),
+			cache.WithLastRefreshTime(lastRefresh),
+			cache.WithFetchFunc(fetchFunc, time.Minute),
+			cache.WithAlignedRefresh(tc.offset),
+			cache.WithLogger(cache.NopLogger()),
+		)
+		s.Stop()
+
+		item, _ := s.Get("key")
+		if !item.NextRefresh().Equal(tc.next) {
+			t.Fatal("next refresh should be aligned, offset:", tc.offset, "next:", item.NextRefresh())
+		}
+	}
+}
+
+func TestAlignedRefreshSchedule(t *testing.T) {
+	var fetches int32
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		atomic.AddInt32(&fetches, 1)
+		return map[string]string{"key": "value"}, nil
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, 20*time.Millisecond),
+		cache.WithAlignedRefresh(0),
+	)
+	defer s.Stop()
+
+	if err := s.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "aligned source should be refreshed", func() bool {
+		return atomic.LoadInt32(&fetches) >= 3
+	})
+
+	item, _ := s.Get("key")
+	if item.NextRefresh().UnixNano()%int64(20*time.Millisecond) != 0 {
+		t.Fatal("next refresh should be on a boundary:", item.NextRefresh())
+	}
+}
+
+func TestRefreshJitter(t *testing.T) {
+	sched := cache.NewScheduler()
+	defer sched.Stop()
+
+	for _, opts := range [][]cache.Option{
+		nil,
+		{cache.WithScheduler(sched)},
+	} {
+		var fetches int32
+		fetchFunc := func(ctx context.Context) (map[string]string, error) {
+			atomic.AddInt32(&fetches, 1)
+			return map[string]string{"key": "value"}, nil
+		}
+
+		s := cache.NewSource(
+			"test",
+			append(
+				opts,
+				cache.WithFetchFunc(fetchFunc, 5*time.Millisecond),
+				cache.WithRefreshJitter(10*time.Millisecond),
+				cache.WithInitialDelay(10*time.Millisecond),
+			)...,
+		)
+
+		if err := s.WaitReady(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		eventually(t, "jittered source should be refreshed", func() bool {
+			return atomic.LoadInt32(&fetches) >= 3
+		})
+
+		// Jitter doesn't change the nominal schedule of items
+		item, _ := s.Get("key")
+		if d := item.NextRefresh().Sub(item.LastRefreshed()); d != 5*time.Millisecond {
+			t.Fatal("next refresh shouldn't include jitter:", d)
+		}
+		s.Stop()
+	}
+}
@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy decides whether a failed fetch should be retried and how long
+// the source should wait before the next attempt.
+type RetryPolicy interface {
+	// Next is called after a failed attempt. The attempt is the number of
+	// attempts made so far (starting at 1) and elapsed is the time since the
+	// first attempt started. Returning false stops retrying.
+	Next(attempt int, elapsed time.Duration) (wait time.Duration, retry bool)
+}
+
+// ConstantRetry returns a policy that waits a fixed period between attempts
+// and gives up after maxAttempts attempts in total.
+func ConstantRetry(wait time.Duration, maxAttempts int) RetryPolicy {
+	return &constantRetry{
+		wait:        wait,
+		maxAttempts: maxAttempts,
+	}
+}
+
+type constantRetry struct {
+	wait        time.Duration
+	maxAttempts int
+}
+
+func (r *constantRetry) Next(attempt int, elapsed time.Duration) (time.Duration, bool) {
+	if attempt >= r.maxAttempts {
+		return 0, false
+	}
+	return r.wait, true
+}
+
+// NoRetry returns a policy that never retries a failed fetch.
+func NoRetry() RetryPolicy {
+	return ConstantRetry(0, 1)
+}
+
+// ExponentialBackoff returns a policy that doubles the wait period after
+// each attempt, starting at initial and capped at maxWait. The actual wait is
+// picked randomly between zero and the computed period (full jitter) so that
+// sources failing at the same time don't retry in lockstep. A maxWait of zero
+// doesn't cap the period. A maxAttempts of zero means the policy retries until
+// the source is stopped.
+func ExponentialBackoff(initial, maxWait time.Duration, maxAttempts int) RetryPolicy {
+	return &exponentialBackoff{
+		initial:     initial,
+		max:         maxWait,
+		maxAttempts: maxAttempts,
+	}
+}
+
+type exponentialBackoff struct {
+	initial     time.Duration
+	max         time.Duration
+	maxAttempts int
+}
+
+func (r *exponentialBackoff) Next(attempt int, elapsed time.Duration) (time.Duration, bool) {
+	if r.maxAttempts > 0 && attempt >= r.maxAttempts {
+		return 0, false
+	}
+
+	capped := r.max > 0
+	backoff := r.initial
+	for i := 1; i < attempt && (!capped || backoff < r.max); i++ {
+		// Stop doubling before the period overflows
+		if backoff > math.MaxInt64/2 {
+			break
+		}
+		backoff *= 2
+	}
+	if capped && backoff > r.max {
+		backoff = r.max
+	}
+
+	if backoff <= 0 {
+		return 0, true
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1)), true
+}
+
+// MaxElapsedTime wraps a policy and stops retrying once the time spent in
+// the current refresh exceeds d.
+func MaxElapsedTime(p RetryPolicy, d time.Duration) RetryPolicy {
+	return &maxElapsedTime{
+		policy: p,
+		max:    d,
+	}
+}
+
+type maxElapsedTime struct {
+	policy RetryPolicy
+	max    time.Duration
+}
+
+func (r *maxElapsedTime) Next(attempt int, elapsed time.Duration) (time.Duration, bool) {
+	wait, retry := r.policy.Next(attempt, elapsed)
+	if !retry || elapsed+wait > r.max {
+		return 0, false
+	}
+	return wait, true
+}
+
+// Permanent marks an error returned from a FetchFunc as permanent. The
+// source won't retry the fetch and waits for the next scheduled refresh.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether any error in the chain was marked with
+// Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// RefreshEvent describes a single fetch attempt made during a refresh.
+type RefreshEvent struct {
+	// Source is the name of the refreshed source
+	Source string
+
+	// Attempt is the number of the attempt within the refresh, starting at 1
+	Attempt int
+
+	// Duration of the fetch function call
+	Duration time.Duration
+
+	// Err returned by the fetch function, nil on success
+	Err error
+
+	// Wait before the next attempt, only set when the fetch will be retried
+	Wait time.Duration
+
+	// Done is true for the last attempt of the refresh. Err then holds the
+	// final outcome of the refresh.
+	Done bool
+}
+
+// RefreshHook is called after every fetch attempt. It is called from the
+// refresh goroutine and should return quickly.
+type RefreshHook func(RefreshEvent)
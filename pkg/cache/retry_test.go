@@ -0,0 +1,115 @@
+package cache_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+func TestConstantRetry(t *testing.T) {
+	p := cache.ConstantRetry(10*time.Millisecond, 3)
+
+	for attempt := 1; attempt < 3; attempt++ {
+		wait, retry := p.Next(attempt, 0)
+		if !retry {
+			t.Fatal("attempt", attempt, "should be retried")
+		}
+		if wait != 10*time.Millisecond {
+			t.Fatal("wrong wait period", wait)
+		}
+	}
+
+	if _, retry := p.Next(3, 0); retry {
+		t.Fatal("policy should give up after 3 attempts")
+	}
+
+	if _, retry := cache.NoRetry().Next(1, 0); retry {
+		t.Fatal("no retry policy shouldn't retry")
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	p := cache.ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond, 10)
+
+	caps := []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		40 * time.Millisecond,
+		50 * time.Millisecond,
+		50 * time.Millisecond,
+	}
+	for i, max := range caps {
+		for n := 0; n < 100; n++ {
+			wait, retry := p.Next(i+1, 0)
+			if !retry {
+				t.Fatal("attempt", i+1, "should be retried")
+			}
+			if wait < 0 || wait > max {
+				t.Fatal("wait", wait, "for attempt", i+1, "should be within", max)
+			}
+		}
+	}
+
+	if _, retry := p.Next(10, 0); retry {
+		t.Fatal("policy should give up after max attempts")
+	}
+
+	if _, retry := cache.ExponentialBackoff(time.Millisecond, time.Second, 0).Next(1000, 0); !retry {
+		t.Fatal("policy without max attempts should always retry")
+	}
+}
+
+func TestExponentialBackoffWithoutCap(t *testing.T) {
+	p := cache.ExponentialBackoff(time.Second, 0, 0)
+
+	var longest time.Duration
+	for n := 0; n < 100; n++ {
+		wait, retry := p.Next(5, 0)
+		if !retry || wait < 0 || wait > 16*time.Second {
+			t.Fatal("wait", wait, "should be within", 16*time.Second)
+		}
+		if wait > longest {
+			longest = wait
+		}
+	}
+	if longest <= time.Second {
+		t.Fatal("zero max wait shouldn't cap the backoff, longest wait:", longest)
+	}
+
+	if wait, retry := p.Next(1000, 0); !retry || wait < 0 {
+		t.Fatal("long backoff shouldn't overflow:", wait)
+	}
+}
+
+func TestMaxElapsedTime(t *testing.T) {
+	p := cache.MaxElapsedTime(cache.ConstantRetry(10*time.Millisecond, 100), 50*time.Millisecond)
+
+	if _, retry := p.Next(1, 20*time.Millisecond); !retry {
+		t.Fatal("policy should retry within max elapsed time")
+	}
+
+	if _, retry := p.Next(2, 45*time.Millisecond); retry {
+		t.Fatal("policy shouldn't wait past max elapsed time")
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	if cache.Permanent(nil) != nil {
+		t.Fatal("permanent nil error should be nil")
+	}
+
+	base := errors.New("error")
+	err := fmt.Errorf("wrapped: %w", cache.Permanent(base))
+	if !cache.IsPermanent(err) {
+		t.Fatal("wrapped permanent error should be detected")
+	}
+	if !errors.Is(err, base) {
+		t.Fatal("permanent error should unwrap to the original error")
+	}
+	if cache.IsPermanent(base) {
+		t.Fatal("plain error shouldn't be permanent")
+	}
+}
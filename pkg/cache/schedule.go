@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Schedule decides when a source is refreshed
+type Schedule interface {
+	// Next returns the time of the refresh following one at t. Returning
+	// Never stops scheduled refreshes.
+	Next(t time.Time) time.Time
+}
+
+// adaptiveSchedule is implemented by schedules adjusting to refresh results.
+// RefreshDone is called after every refresh with whether it changed the data
+// or the error of a failed refresh.
+type adaptiveSchedule interface {
+	RefreshDone(changed bool, err error)
+}
+
+// intervalSchedule is implemented by schedules with a known refresh interval
+type intervalSchedule interface {
+	Interval() time.Duration
+}
+
+// Every returns a schedule refreshing a fixed period d after the previous
+// refresh. Zero or negative d never refreshes.
+func Every(d time.Duration) Schedule {
+	return every(d)
+}
+
+type every time.Duration
+
+func (d every) Next(t time.Time) time.Time {
+	if d <= 0 {
+		return Never
+	}
+	return t.Add(time.Duration(d))
+}
+
+func (d every) Interval() time.Duration {
+	return time.Duration(d)
+}
+
+// Aligned returns a schedule refreshing on multiples of d since midnight UTC
+// shifted by offset, e.g. every minute on :00. Instances using the same
+// schedule refresh at the same time regardless of when they started. The
+// period d should divide a day.
+func Aligned(d, offset time.Duration) Schedule {
+	return &aligned{period: d, offset: offset}
+}
+
+type aligned struct {
+	period time.Duration
+	offset time.Duration
+}
+
+// Next returns the first multiple after t. Multiples are counted from the zero
+// time, which is midnight UTC.
+func (a *aligned) Next(t time.Time) time.Time {
+	if a.period <= 0 {
+		return Never
+	}
+	return t.Add(-a.offset).Truncate(a.period).Add(a.period + a.offset)
+}
+
+func (a *aligned) Interval() time.Duration {
+	return a.period
+}
+
+// Adaptive returns a schedule moving the refresh interval between min and
+// max according to how often refreshes change data. It starts at min, a
+// refresh that changed data halves the interval and one that didn't lengthens
+// it by half. A failed refresh doubles the interval to back off from the
+// backend. Each source needs its own adaptive schedule. It panics unless
+// 0 < min <= max.
+func Adaptive(min, max time.Duration) Schedule {
+	if min <= 0 || min > max {
+		panic("invalid adaptive schedule bounds")
+	}
+	return &adaptive{min: min, max: max, interval: min}
+}
+
+type adaptive struct {
+	min, max time.Duration
+
+	lock     sync.Mutex
+	interval time.Duration
+}
+
+func (a *adaptive) Next(t time.Time) time.Time {
+	return t.Add(a.Interval())
+}
+
+func (a *adaptive) Interval() time.Duration {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.interval
+}
+
+func (a *adaptive) RefreshDone(changed bool, err error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	switch {
+	case err != nil:
+		a.interval *= 2
+	case changed:
+		a.interval /= 2
+	default:
+		a.interval += a.interval / 2
+	}
+
+	if a.interval < a.min {
+		a.interval = a.min
+	}
+	if a.interval > a.max {
+		a.interval = a.max
+	}
+}
+
+// Blackout returns a schedule that postpones refreshes of s falling into a
+// daily window to the end of the window, e.g. to avoid refreshes during batch
+// imports. The window starts from and ends to after midnight in loc, a window
+// ending before it starts spans midnight. A nil loc uses local time.
+//
+//	// No refreshes between 02:00 and 03:00 UTC
+//	cache.Blackout(cache.Every(time.Minute), 2*time.Hour, 3*time.Hour, time.UTC)
+func Blackout(s Schedule, from, to time.Duration, loc *time.Location) Schedule {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &blackout{schedule: s, from: from, to: to, loc: loc}
+}
+
+type blackout struct {
+	schedule Schedule
+	from     time.Duration
+	to       time.Duration
+	loc      *time.Location
+}
+
+func (b *blackout) Next(t time.Time) time.Time {
+	next := b.schedule.Next(t)
+	if next.Equal(Never) || b.from == b.to {
+		return next
+	}
+
+	// A window spanning midnight may have started the previous day
+	y, m, d := next.In(b.loc).Date()
+	for _, day := range []int{d - 1, d} {
+		start := b.clock(y, m, day, b.from)
+		end := b.clock(y, m, day, b.to)
+		if b.to < b.from {
+			end = b.clock(y, m, day+1, b.to)
+		}
+
+		if !next.Before(start) && next.Before(end) {
+			return end
+		}
+	}
+	return next
+}
+
+// clock returns wall-clock time d after midnight of the day in loc
+func (b *blackout) clock(y int, m time.Month, day int, d time.Duration) time.Time {
+	return time.Date(y, m, day, 0, 0, 0, int(d), b.loc)
+}
+
+// RefreshDone passes refresh results to an adaptive schedule in the window
+func (b *blackout) RefreshDone(changed bool, err error) {
+	if a, ok := b.schedule.(adaptiveSchedule); ok {
+		a.RefreshDone(changed, err)
+	}
+}
+
+// Interval returns the interval of the schedule in the window, if known
+func (b *blackout) Interval() time.Duration {
+	if i, ok := b.schedule.(intervalSchedule); ok {
+		return i.Interval()
+	}
+	return 0
+}
@@ -0,0 +1,279 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+type scheduleFunc func(t time.Time) time.Time
+
+func (f scheduleFunc) Next(t time.Time) time.Time {
+	return f(t)
+}
+
+func date(t *testing.T, loc *time.Location, value string) time.Time {
+	t.Helper()
+
+	d, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestEvery(t *testing.T) {
+	now := time.Now()
+	if next := cache.Every(time.Minute).Next(now); !next.Equal(now.Add(time.Minute)) {
+		t.Fatal("next refresh should be a period later:", next)
+	}
+	if next := cache.Every(0).Next(now); !next.Equal(cache.Never) {
+		t.Fatal("zero period shouldn't refresh:", next)
+	}
+}
+
+func TestAligned(t *testing.T) {
+	s := cache.Aligned(15*time.Minute, 5*time.Minute)
+
+	for _, tc := range []struct{ from, next string }{
+		{"2020-01-01 10:00", "2020-01-01 10:05"},
+		{"2020-01-01 10:05", "2020-01-01 10:20"},
+		{"2020-01-01 23:55", "2020-01-02 00:05"},
+	} {
+		next := s.Next(date(t, time.UTC, tc.from))
+		if !next.Equal(date(t, time.UTC, tc.next)) {
+			t.Fatal("unexpected next refresh after", tc.from, ":", next)
+		}
+	}
+}
+
+func TestBlackout(t *testing.T) {
+	prague, err := time.LoadLocation("Europe/Prague")
+	if err != nil {
+		t.Skip("time zone database not available:", err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		from, to time.Duration
+		loc      *time.Location
+		after    string
+		next     string
+	}{
+		{"before window", 2 * time.Hour, 3 * time.Hour, time.UTC, "2020-01-01 01:00", "2020-01-01 01:30"},
+		{"in window", 2 * time.Hour, 3 * time.Hour, time.UTC, "2020-01-01 01:45", "2020-01-01 03:00"},
+		{"window end", 2 * time.Hour, 3 * time.Hour, time.UTC, "2020-01-01 02:30", "2020-01-01 03:00"},
+		{"over midnight", 23 * time.Hour, time.Hour, time.UTC, "2020-01-01 22:45", "2020-01-02 01:00"},
+		{"after midnight", 23 * time.Hour, time.Hour, time.UTC, "2020-01-02 00:15", "2020-01-02 01:00"},
+		{"time zone", 2 * time.Hour, 3 * time.Hour, prague, "2020-01-01 00:45", "2020-01-01 02:00"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := cache.Blackout(cache.Every(30*time.Minute), tc.from, tc.to, tc.loc)
+			next := s.Next(date(t, time.UTC, tc.after))
+			if !next.Equal(date(t, time.UTC, tc.next)) {
+				t.Fatal("unexpected next refresh:", next.UTC())
+			}
+		})
+	}
+}
+
+func TestCron(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database not available:", err)
+	}
+
+	for _, tc := range []struct {
+		expr  string
+		loc   *time.Location
+		after string
+		next  string
+	}{
+		{"* * * * *", time.UTC, "2020-01-01 10:00", "2020-01-01 10:01"},
+		{"*/15 * * * *", time.UTC, "2020-01-01 10:07", "2020-01-01 10:15"},
+		{"5/20 * * * *", time.UTC, "2020-01-01 10:46", "2020-01-01 11:05"},
+		{"0,30 9-17 * * *", time.UTC, "2020-01-01 17:30", "2020-01-02 09:00"},
+		{"0 0 1 * *", time.UTC, "2020-01-15 12:00", "2020-02-01 00:00"},
+		{"0 12 * * mon-fri", time.UTC, "2020-01-03 13:00", "2020-01-06 12:00"},
+		{"0 0 * * 7", time.UTC, "2020-01-01 00:00", "2020-01-05 00:00"},
+		{"0 0 1 jan *", time.UTC, "2020-06-01 00:00", "2021-01-01 00:00"},
+		{"0 0 29 2 *", time.UTC, "2021-01-01 00:00", "2024-02-29 00:00"},
+		// Day of month or day of week when both are restricted
+		{"0 0 13 * fri", time.UTC, "2020-01-01 00:00", "2020-01-03 00:00"},
+		{"@hourly", time.UTC, "2020-01-01 10:30", "2020-01-01 11:00"},
+		{"@daily", newYork, "2020-01-01 10:30", "2020-01-02 05:00"},
+		{"0 9 * * *", newYork, "2020-07-01 12:00", "2020-07-01 13:00"},
+		// 02:30 doesn't exist when daylight saving time starts
+		{"30 2 * * *", newYork, "2020-03-08 05:00", "2020-03-09 06:30"},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			s, err := cache.Cron(tc.expr, tc.loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			next := s.Next(date(t, time.UTC, tc.after))
+			if !next.Equal(date(t, time.UTC, tc.next)) {
+				t.Fatal("unexpected next refresh:", next.UTC())
+			}
+		})
+	}
+}
+
+func TestCronNeverMatches(t *testing.T) {
+	s := cache.MustCron("0 0 30 2 *", time.UTC)
+	if next := s.Next(time.Now()); !next.Equal(cache.Never) {
+		t.Fatal("expression without a match shouldn't refresh:", next)
+	}
+}
+
+func TestCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"@often",
+	} {
+		if _, err := cache.Cron(expr, time.UTC); err == nil {
+			t.Fatal("invalid expression should fail:", expr)
+		}
+	}
+}
+
+func TestSourceSchedule(t *testing.T) {
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		return nil, errors.New("error")
+	}
+	lastRefresh := time.Date(2020, 1, 1, 10, 7, 0, 0, time.UTC)
+
+	s := cache.NewSource(
+		"test",
+		cache.WithDefaultData(map[string]string{"key": "value"}),
+		cache.WithLastRefreshTime(lastRefresh),
+		cache.WithFetchFunc(fetchFunc, time.Minute),
+		cache.WithSchedule(cache.MustCron("*/15 * * * *", time.UTC)),
+		cache.WithLogger(cache.NopLogger()),
+	)
+	s.Stop()
+
+	item, _ := s.Get("key")
+	if next := item.NextRefresh(); !next.Equal(time.Date(2020, 1, 1, 10, 15, 0, 0, time.UTC)) {
+		t.Fatal("next refresh should follow the schedule:", next)
+	}
+}
+
+func TestSourceScheduleStops(t *testing.T) {
+	sched := cache.NewScheduler()
+	defer sched.Stop()
+
+	for _, opts := range [][]cache.Option{
+		nil,
+		{cache.WithScheduler(sched)},
+	} {
+		var fetches int32
+		fetchFunc := func(ctx context.Context) (map[string]string, error) {
+			atomic.AddInt32(&fetches, 1)
+			return map[string]string{"key": "value"}, nil
+		}
+
+		// Schedule a single refresh after the initial fetch
+		once := scheduleFunc(func(t time.Time) time.Time {
+			if atomic.LoadInt32(&fetches) >= 2 {
+				return cache.Never
+			}
+			return t.Add(time.Millisecond)
+		})
+
+		s := cache.NewSource(
+			"test",
+			append(opts, cache.WithFetchFunc(fetchFunc, 0), cache.WithSchedule(once))...,
+		)
+
+		eventually(t, "source should be refreshed by schedule", func() bool {
+			return atomic.LoadInt32(&fetches) == 2
+		})
+		<-time.After(20 * time.Millisecond)
+		if f := atomic.LoadInt32(&fetches); f != 2 {
+			t.Fatal("finished schedule shouldn't refresh, fetches:", f)
+		}
+
+		item, _ := s.Get("key")
+		if !item.NextRefresh().Equal(cache.Never) || item.IsStale() {
+			t.Fatal("data shouldn't have next refresh")
+		}
+
+		if err := s.Refresh(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		s.Stop()
+	}
+}
+
+func TestAdaptiveSchedule(t *testing.T) {
+	var changing, failing atomic.Bool
+	var fetches int32
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		v := atomic.AddInt32(&fetches, 1)
+		if failing.Load() {
+			return nil, errors.New("error")
+		}
+		if changing.Load() {
+			return map[string]string{"key": fmt.Sprint(v)}, nil
+		}
+		return map[string]string{"key": "value"}, nil
+	}
+
+	minInterval, maxInterval := 5*time.Millisecond, 40*time.Millisecond
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, 0),
+		cache.WithSchedule(cache.Adaptive(minInterval, maxInterval)),
+		cache.WithRetryPolicy(cache.NoRetry()),
+		cache.WithLogger(cache.NopLogger()),
+	)
+	defer s.Stop()
+
+	if err := s.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, "unchanged data should lengthen the interval", func() bool {
+		return s.Status().RefreshInterval == maxInterval
+	})
+	item, _ := s.Get("key")
+	if d := item.NextRefresh().Sub(item.LastRefreshed()); d != maxInterval {
+		t.Fatal("next refresh should reflect the interval:", d)
+	}
+
+	changing.Store(true)
+	eventually(t, "changing data should shorten the interval", func() bool {
+		return s.Status().RefreshInterval == minInterval
+	})
+
+	failing.Store(true)
+	eventually(t, "failures should back off", func() bool {
+		return s.Status().RefreshInterval == maxInterval
+	})
+}
+
+func TestAdaptiveScheduleBounds(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("invalid bounds should panic")
+		}
+	}()
+	cache.Adaptive(time.Minute, time.Second)
+}
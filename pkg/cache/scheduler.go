@@ -0,0 +1,382 @@
+package cache
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultSchedulerWorkers is the default number of refreshes a scheduler
+// runs concurrently
+const DefaultSchedulerWorkers = 10
+
+// SchedulerOption is a function that sets an option for a scheduler
+type SchedulerOption func(*SchedulerOptions)
+
+// SchedulerOptions for configuring a scheduler. The options shouldn't be used
+// directly but through With... functions.
+type SchedulerOptions struct {
+	Workers       int
+	BackendLimits map[string]int
+	Logger        Logger
+}
+
+// WithWorkers limits the number of refreshes running concurrently across all
+// sources, zero doesn't limit them
+func WithWorkers(n int) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.Workers = n
+	}
+}
+
+// WithBackendLimit limits the number of concurrent refreshes of sources set
+// to backend using WithBackend
+func WithBackendLimit(backend string, n int) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.BackendLimits[backend] = n
+	}
+}
+
+// WithSchedulerLogger provides a custom logger for the scheduler
+func WithSchedulerLogger(l Logger) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.Logger = l
+	}
+}
+
+// Scheduler runs refreshes of many sources from a single goroutine with a
+// timer heap instead of a goroutine and timer per source. Due refreshes are
+// run in the order they became due by a bounded number of workers, with
+// optional limits per backend. Sources use a scheduler when created with
+// WithScheduler.
+type Scheduler struct {
+	workers       int
+	backendLimits map[string]int
+	logger        Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wakeCh chan struct{}
+	doneCh chan struct{}
+
+	// Jobs waiting for their time and due jobs waiting for a worker,
+	// guarded by lock
+	lock    sync.Mutex
+	queue   jobHeap
+	due     []*scheduledJob
+	running int
+	backend map[string]int
+	seq     uint64
+	stopped bool
+
+	// runs tracks running jobs, it's only added to from the loop
+	runs sync.WaitGroup
+}
+
+// scheduledJob is a periodic refresh of a single source
+type scheduledJob struct {
+	name    string
+	backend string
+
+	// run refreshes the source and next returns the time of the following
+	// refresh, false stops scheduling the job
+	run  func()
+	next func(now time.Time) (time.Time, bool)
+
+	// Scheduling state guarded by scheduler lock. Index is the position in
+	// the heap, -1 if the job isn't waiting for its time.
+	at      time.Time
+	seq     uint64
+	index   int
+	running bool
+	removed bool
+	done    chan struct{}
+
+	// once jobs run a single refresh requested outside of the schedule
+	once bool
+}
+
+// jobHeap orders jobs by time, jobs with the same time by scheduling order
+type jobHeap []*scheduledJob
+
+func (h jobHeap) Len() int { return len(h) }
+
+func (h jobHeap) Less(i, j int) bool {
+	if !h[i].at.Equal(h[j].at) {
+		return h[i].at.Before(h[j].at)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h jobHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *jobHeap) Push(x interface{}) {
+	j := x.(*scheduledJob)
+	j.index = len(*h)
+	*h = append(*h, j)
+}
+
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	j := old[len(old)-1]
+	old[len(old)-1] = nil
+	j.index = -1
+	*h = old[:len(old)-1]
+	return j
+}
+
+// NewScheduler creates and starts a scheduler. It should be stopped after
+// all sources using it were stopped.
+func NewScheduler(opts ...SchedulerOption) *Scheduler {
+	o := &SchedulerOptions{
+		Workers:       DefaultSchedulerWorkers,
+		BackendLimits: map[string]int{},
+		Logger:        SlogLogger(nil),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &Scheduler{
+		workers:       o.Workers,
+		backendLimits: o.BackendLimits,
+		logger:        o.Logger,
+		ctx:           ctx,
+		cancel:        cancel,
+		wakeCh:        make(chan struct{}, 1),
+		doneCh:        make(chan struct{}),
+		backend:       map[string]int{},
+	}
+
+	go s.loop()
+	return s
+}
+
+// Stop stops scheduling refreshes and waits for running ones to finish. It
+// is safe to call Stop multiple times.
+func (s *Scheduler) Stop() {
+	s.cancel()
+	<-s.doneCh
+}
+
+func (s *Scheduler) loop() {
+	defer close(s.doneCh)
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		s.lock.Lock()
+		now := time.Now()
+		for len(s.queue) > 0 && !s.queue[0].at.After(now) {
+			s.due = append(s.due, heap.Pop(&s.queue).(*scheduledJob))
+		}
+		s.dispatch()
+
+		wait := time.Duration(-1)
+		if len(s.queue) > 0 {
+			wait = s.queue[0].at.Sub(now)
+		}
+		s.lock.Unlock()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if wait >= 0 {
+			timer.Reset(wait)
+		}
+
+		select {
+		case <-s.ctx.Done():
+			s.drain()
+			s.runs.Wait()
+			return
+		case <-s.wakeCh:
+		case <-timer.C:
+		}
+	}
+}
+
+// dispatch starts due jobs in order as long as workers and their backend
+// have capacity. Jobs of a backend at its limit keep their place, so they run
+// first once the backend frees up. It must be called with lock held.
+func (s *Scheduler) dispatch() {
+	now := time.Now()
+	waiting := s.due[:0]
+	for _, j := range s.due {
+		if j.removed {
+			continue
+		}
+
+		if (s.workers > 0 && s.running >= s.workers) || !s.backendAvailable(j.backend) {
+			waiting = append(waiting, j)
+			continue
+		}
+
+		s.logger.Debug("refreshing source", "source", j.name, "delay", now.Sub(j.at))
+		s.start(j)
+	}
+
+	for i := len(waiting); i < len(s.due); i++ {
+		s.due[i] = nil
+	}
+	s.due = waiting
+}
+
+// start runs the job in a new goroutine, it must be called with lock held
+func (s *Scheduler) start(j *scheduledJob) {
+	j.running = true
+	j.done = make(chan struct{})
+	s.running++
+	s.backend[j.backend]++
+	s.runs.Add(1)
+	go s.run(j)
+}
+
+// drain stops accepting one-off refreshes and starts those still waiting for
+// a worker, so their callers aren't left waiting after Stop
+func (s *Scheduler) drain() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.stopped = true
+	for _, j := range s.due {
+		if j.once && !j.removed {
+			s.start(j)
+		}
+	}
+	s.due = nil
+}
+
+func (s *Scheduler) backendAvailable(backend string) bool {
+	limit, ok := s.backendLimits[backend]
+	return !ok || limit <= 0 || s.backend[backend] < limit
+}
+
+// run runs the job and schedules its next refresh
+func (s *Scheduler) run(j *scheduledJob) {
+	defer s.runs.Done()
+	j.run()
+
+	s.lock.Lock()
+	s.running--
+	s.backend[j.backend]--
+	j.running = false
+	close(j.done)
+	if !j.removed {
+		if at, ok := j.next(time.Now()); ok {
+			s.push(j, at)
+		}
+	}
+	s.lock.Unlock()
+
+	s.wake()
+}
+
+// push schedules the job at time at, it must be called with lock held
+func (s *Scheduler) push(j *scheduledJob, at time.Time) {
+	s.seq++
+	j.at = at
+	j.seq = s.seq
+	heap.Push(&s.queue, j)
+}
+
+func (s *Scheduler) wake() {
+	select {
+	case s.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// add schedules a new job at time at
+func (s *Scheduler) add(j *scheduledJob, at time.Time) {
+	s.lock.Lock()
+	j.index = -1
+	s.push(j, at)
+	s.lock.Unlock()
+
+	s.wake()
+}
+
+// runOnce runs fn once for the job outside of its schedule. It's queued
+// behind refreshes that are already due and limited by workers and the
+// backend of the job like scheduled refreshes. It returns false without
+// running fn if the scheduler is stopped.
+func (s *Scheduler) runOnce(j *scheduledJob, fn func()) bool {
+	s.lock.Lock()
+	if s.stopped {
+		s.lock.Unlock()
+		return false
+	}
+	s.due = append(s.due, &scheduledJob{
+		name:    j.name,
+		backend: j.backend,
+		run:     fn,
+		next: func(time.Time) (time.Time, bool) {
+			return time.Time{}, false
+		},
+		at:    time.Now(),
+		index: -1,
+		once:  true,
+	})
+	s.lock.Unlock()
+
+	s.wake()
+	return true
+}
+
+// reschedule moves a job waiting for its time to at and schedules a job
+// without planned refresh. Due and running jobs are left alone, running ones
+// are scheduled after they finish.
+func (s *Scheduler) reschedule(j *scheduledJob, at time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if j.removed || j.running {
+		return
+	}
+
+	if j.index >= 0 {
+		j.at = at
+		heap.Fix(&s.queue, j.index)
+		s.wake()
+		return
+	}
+
+	for _, d := range s.due {
+		if d == j {
+			return
+		}
+	}
+	s.push(j, at)
+	s.wake()
+}
+
+// remove stops scheduling the job and waits if it's running
+func (s *Scheduler) remove(j *scheduledJob) {
+	s.lock.Lock()
+	j.removed = true
+	if j.index >= 0 {
+		heap.Remove(&s.queue, j.index)
+	}
+	var done chan struct{}
+	if j.running {
+		done = j.done
+	}
+	s.lock.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
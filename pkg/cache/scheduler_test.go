@@ -0,0 +1,279 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+// concurrencyTracker records the maximum number of concurrent fetches
+type concurrencyTracker struct {
+	lock    sync.Mutex
+	current int
+	max     int
+}
+
+func (c *concurrencyTracker) fetchFunc(d time.Duration, fetches *int32) cache.FetchFunc {
+	return func(ctx context.Context) (map[string]string, error) {
+		c.lock.Lock()
+		c.current++
+		if c.current > c.max {
+			c.max = c.current
+		}
+		c.lock.Unlock()
+
+		<-time.After(d)
+		v := atomic.AddInt32(fetches, 1)
+
+		c.lock.Lock()
+		c.current--
+		c.lock.Unlock()
+
+		return map[string]string{"key": fmt.Sprint(v)}, nil
+	}
+}
+
+func (c *concurrencyTracker) maxConcurrent() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.max
+}
+
+func TestScheduler(t *testing.T) {
+	sched := cache.NewScheduler()
+	defer sched.Stop()
+
+	var fetches [5]int32
+	tracker := &concurrencyTracker{}
+	var sources []cache.StoppableSource
+	for i := range fetches {
+		s := cache.NewSource(
+			fmt.Sprint("s", i),
+			cache.WithFetchFunc(tracker.fetchFunc(0, &fetches[i]), 10*time.Millisecond),
+			cache.WithScheduler(sched),
+		)
+		defer s.Stop()
+		sources = append(sources, s)
+	}
+
+	for i, s := range sources {
+		if err := s.WaitReady(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		eventually(t, "scheduled source should be refreshed", func() bool {
+			return atomic.LoadInt32(&fetches[i]) >= 3
+		})
+	}
+}
+
+func TestSchedulerWorkers(t *testing.T) {
+	sched := cache.NewScheduler(cache.WithWorkers(1))
+	defer sched.Stop()
+
+	var fetches [3]int32
+	tracker := &concurrencyTracker{}
+	for i := range fetches {
+		s := cache.NewSource(
+			fmt.Sprint("s", i),
+			cache.WithFetchFunc(tracker.fetchFunc(5*time.Millisecond, &fetches[i]), time.Millisecond),
+			cache.WithScheduler(sched),
+		)
+		defer s.Stop()
+	}
+
+	// Sources are due all the time, each of them gets its turn
+	for i := range fetches {
+		eventually(t, "due sources should be refreshed in turns", func() bool {
+			return atomic.LoadInt32(&fetches[i]) >= 3
+		})
+	}
+
+	if m := tracker.maxConcurrent(); m != 1 {
+		t.Fatal("refreshes should be limited by workers, max concurrent:", m)
+	}
+}
+
+func TestSchedulerBackendLimit(t *testing.T) {
+	sched := cache.NewScheduler(cache.WithBackendLimit("db", 2))
+	defer sched.Stop()
+
+	var dbFetches [4]int32
+	db := &concurrencyTracker{}
+	for i := range dbFetches {
+		s := cache.NewSource(
+			fmt.Sprint("db", i),
+			cache.WithFetchFunc(db.fetchFunc(5*time.Millisecond, &dbFetches[i]), time.Millisecond),
+			cache.WithScheduler(sched),
+			cache.WithBackend("db"),
+		)
+		defer s.Stop()
+	}
+
+	var otherFetches int32
+	other := &concurrencyTracker{}
+	s := cache.NewSource(
+		"other",
+		cache.WithFetchFunc(other.fetchFunc(0, &otherFetches), time.Millisecond),
+		cache.WithScheduler(sched),
+	)
+	defer s.Stop()
+
+	eventually(t, "other backends shouldn't wait for a busy backend", func() bool {
+		return atomic.LoadInt32(&otherFetches) >= 5
+	})
+	for i := range dbFetches {
+		eventually(t, "backend sources should be refreshed", func() bool {
+			return atomic.LoadInt32(&dbFetches[i]) >= 2
+		})
+	}
+
+	if m := db.maxConcurrent(); m > 2 {
+		t.Fatal("refreshes should be limited per backend, max concurrent:", m)
+	}
+}
+
+func TestSchedulerStopSource(t *testing.T) {
+	sched := cache.NewScheduler()
+	defer sched.Stop()
+
+	var fetches int32
+	tracker := &concurrencyTracker{}
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(tracker.fetchFunc(0, &fetches), 5*time.Millisecond),
+		cache.WithScheduler(sched),
+	)
+	s.WaitReady(context.Background())
+	s.Stop()
+
+	stopped := atomic.LoadInt32(&fetches)
+	<-time.After(30 * time.Millisecond)
+	if f := atomic.LoadInt32(&fetches); f != stopped {
+		t.Fatal("stopped source shouldn't be refreshed")
+	}
+}
+
+func TestSchedulerOwnedByCache(t *testing.T) {
+	sched := cache.NewScheduler()
+
+	var fetches int32
+	tracker := &concurrencyTracker{}
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(tracker.fetchFunc(0, &fetches), time.Hour),
+		cache.WithScheduler(sched),
+	)
+	c := cache.NewWithOptions([]cache.Source{s}, cache.WithCacheScheduler(sched))
+
+	if err := c.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Scheduler is already stopped
+	sched.Stop()
+}
+
+func TestSchedulerManualRefresh(t *testing.T) {
+	sched := cache.NewScheduler()
+	defer sched.Stop()
+
+	var fetches int32
+	tracker := &concurrencyTracker{}
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(tracker.fetchFunc(0, &fetches), 100*time.Millisecond),
+		cache.WithScheduler(sched),
+	)
+	defer s.Stop()
+	s.WaitReady(context.Background())
+
+	<-time.After(60 * time.Millisecond)
+	if err := s.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Scheduled refresh was moved a full period after the manual one
+	<-time.After(60 * time.Millisecond)
+	if f := atomic.LoadInt32(&fetches); f != 2 {
+		t.Fatal("scheduled refresh should be moved after manual refresh, fetches:", f)
+	}
+}
+
+func TestSchedulerNeverRefresh(t *testing.T) {
+	sched := cache.NewScheduler()
+	defer sched.Stop()
+
+	var otherFetches int32
+	other := cache.NewSource(
+		"other",
+		cache.WithFetchFunc((&concurrencyTracker{}).fetchFunc(0, &otherFetches), 5*time.Millisecond),
+		cache.WithScheduler(sched),
+	)
+	defer other.Stop()
+
+	// Default data with zero frequency is never refreshed
+	var fetches int32
+	s := cache.NewSource(
+		"test",
+		cache.WithDefaultData(map[string]string{"key": "value"}),
+		cache.WithFetchFunc((&concurrencyTracker{}).fetchFunc(0, &fetches), 0),
+		cache.WithScheduler(sched),
+	)
+
+	<-time.After(30 * time.Millisecond)
+	if f := atomic.LoadInt32(&fetches); f != 0 {
+		t.Fatal("source without schedule shouldn't be refreshed, fetches:", f)
+	}
+
+	if err := s.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	s.Stop()
+
+	// Stopping the source doesn't affect other jobs
+	refreshed := atomic.LoadInt32(&otherFetches)
+	eventually(t, "other source should be refreshed", func() bool {
+		return atomic.LoadInt32(&otherFetches) > refreshed
+	})
+}
+
+func TestSchedulerRefreshAll(t *testing.T) {
+	sched := cache.NewScheduler(cache.WithWorkers(1))
+
+	var fetches [5]int32
+	tracker := &concurrencyTracker{}
+	var sources []cache.Source
+	for i := range fetches {
+		// Default data isn't fetched until the scheduled refresh
+		sources = append(sources, cache.NewSource(
+			fmt.Sprint("s", i),
+			cache.WithDefaultData(map[string]string{"key": "value"}),
+			cache.WithFetchFunc(tracker.fetchFunc(5*time.Millisecond, &fetches[i]), time.Hour),
+			cache.WithScheduler(sched),
+		))
+	}
+	c := cache.NewWithOptions(sources, cache.WithCacheScheduler(sched))
+	defer c.Close(context.Background())
+
+	if err := c.RefreshAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range fetches {
+		if f := atomic.LoadInt32(&fetches[i]); f != 1 {
+			t.Fatal("source should be refreshed, fetches:", f)
+		}
+	}
+	if m := tracker.maxConcurrent(); m != 1 {
+		t.Fatal("manual refreshes should be limited by workers, max concurrent:", m)
+	}
+}
@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// snapshotVersion is increased on incompatible changes of the snapshot format
+const snapshotVersion = 2
+
+var (
+	errSnapshotCorrupt      = errors.New("snapshot is corrupt")
+	errSnapshotIncompatible = errors.New("snapshot is incompatible")
+)
+
+// snapshot is an on-disk representation of source data. Values are stored as
+// bytes, encoded in base64, as JSON strings can't hold binary values.
+type snapshot struct {
+	Version       int               `json:"version"`
+	Source        string            `json:"source"`
+	LastRefreshed time.Time         `json:"last_refreshed"`
+	Checksum      string            `json:"checksum"`
+	Data          map[string][]byte `json:"data"`
+}
+
+// snapshotChecksum hashes length prefixed keys and values in key order, so
+// it's computed over the exact data rather than its encoding
+func snapshotChecksum(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	var buf []byte
+	for _, k := range keys {
+		buf = binary.AppendUvarint(buf[:0], uint64(len(k)))
+		buf = append(buf, k...)
+		buf = binary.AppendUvarint(buf, uint64(len(data[k])))
+		h.Write(buf)
+		h.Write(data[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// saveSnapshot atomically writes source data to path. The data is written to
+// a temporary file in the same directory which then replaces the snapshot.
+func saveSnapshot(path string, name string, data map[string]string, lastRefreshed time.Time) error {
+	values := make(map[string][]byte, len(data))
+	for k, v := range data {
+		values[k] = []byte(v)
+	}
+
+	b, err := json.Marshal(&snapshot{
+		Version:       snapshotVersion,
+		Source:        name,
+		LastRefreshed: lastRefreshed,
+		Checksum:      snapshotChecksum(values),
+		Data:          values,
+	})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// loadSnapshot reads source data written by saveSnapshot. Snapshots that
+// can't be decoded, don't match their checksum, or were written by another
+// source or format version are rejected.
+func loadSnapshot(path string, name string) (map[string]string, time.Time, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Never, err
+	}
+
+	s := &snapshot{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, Never, fmt.Errorf("%w: %v", errSnapshotCorrupt, err)
+	}
+
+	if s.Version != snapshotVersion {
+		return nil, Never, fmt.Errorf("%w: version %d", errSnapshotIncompatible, s.Version)
+	}
+
+	if s.Source != name {
+		return nil, Never, fmt.Errorf("%w: written by source %q", errSnapshotIncompatible, s.Source)
+	}
+
+	if s.Data == nil || snapshotChecksum(s.Data) != s.Checksum {
+		return nil, Never, fmt.Errorf("%w: checksum mismatch", errSnapshotCorrupt)
+	}
+
+	data := make(map[string]string, len(s.Data))
+	for k, v := range s.Data {
+		data[k] = string(v)
+	}
+	return data, s.LastRefreshed, nil
+}
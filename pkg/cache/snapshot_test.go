@@ -0,0 +1,211 @@
+package cache_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+func TestSnapshotWarmStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		return map[string]string{"key": "value"}, nil
+	}
+
+	done := make(chan struct{})
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+		cache.WithSnapshot(path),
+		cache.WithRefreshHook(func(e cache.RefreshEvent) {
+			close(done)
+		}),
+	)
+	<-done
+	s.Stop()
+
+	item, err := s.Get("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastRefresh := item.LastRefreshed()
+
+	// The database is down on the next start
+	failingFetchFunc := func(ctx context.Context) (map[string]string, error) {
+		return nil, fmt.Errorf("connection refused")
+	}
+
+	s = cache.NewSource(
+		"test",
+		cache.WithFetchFunc(failingFetchFunc, time.Hour),
+		cache.WithSnapshot(path),
+	)
+	defer s.Stop()
+
+	item, err = s.Get("key")
+	if err != nil {
+		t.Fatal("snapshot data should be served:", err)
+	}
+	if item.Value() != "value" {
+		t.Fatal("wrong value loaded from snapshot")
+	}
+	if !item.LastRefreshed().Equal(lastRefresh) {
+		t.Fatal("snapshot should preserve last refresh time")
+	}
+}
+
+func TestSnapshotBinaryValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	value := []byte{0xff, 0xfe, 0x00, 0x81}
+
+	fetchFunc := func(ctx context.Context) (map[string][]byte, error) {
+		return map[string][]byte{"key": value}, nil
+	}
+
+	done := make(chan struct{})
+	s := cache.NewSource(
+		"test",
+		cache.WithBytesFetchFunc(fetchFunc, time.Hour),
+		cache.WithSnapshot(path),
+		cache.WithRefreshHook(func(e cache.RefreshEvent) {
+			close(done)
+		}),
+	)
+	<-done
+	s.Stop()
+
+	failingFetchFunc := func(ctx context.Context) (map[string]string, error) {
+		return nil, fmt.Errorf("connection refused")
+	}
+
+	s = cache.NewSource(
+		"test",
+		cache.WithFetchFunc(failingFetchFunc, time.Hour),
+		cache.WithSnapshot(path),
+	)
+	defer s.Stop()
+
+	item, err := s.Get("key")
+	if err != nil {
+		t.Fatal("snapshot data should be served:", err)
+	}
+	if !bytes.Equal(item.ValueBytes(), value) {
+		t.Fatalf("binary value should survive snapshot: %x", item.ValueBytes())
+	}
+}
+
+func TestSnapshotStaleData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	lastRefresh := time.Now().Add(-1 * time.Hour).Truncate(time.Second)
+	writeSnapshotFile(t, path, "test", map[string]string{"key": "old"}, lastRefresh)
+
+	fetched := make(chan struct{})
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		select {
+		case <-fetched:
+		case <-ctx.Done():
+		}
+		return map[string]string{"key": "value"}, nil
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, time.Minute),
+		cache.WithSnapshot(path),
+	)
+	defer s.Stop()
+
+	item, err := s.Get("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Value() != "old" || !item.LastRefreshed().Equal(lastRefresh) {
+		t.Fatal("snapshot data should be served with original refresh time")
+	}
+	if !item.IsStale() {
+		t.Fatal("old snapshot data should be stale")
+	}
+
+	select {
+	case fetched <- struct{}{}:
+	case <-time.After(time.Second):
+		t.Fatal("stale snapshot should be refreshed immediately")
+	}
+}
+
+func writeSnapshotFile(t *testing.T, path string, source string, data map[string]string, lastRefresh time.Time) {
+	keys := make([]string, 0, len(data))
+	values := map[string][]byte{}
+	for k, v := range data {
+		keys = append(keys, k)
+		values[k] = []byte(v)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write(binary.AppendUvarint(nil, uint64(len(k))))
+		h.Write([]byte(k))
+		h.Write(binary.AppendUvarint(nil, uint64(len(data[k]))))
+		h.Write([]byte(data[k]))
+	}
+
+	b, err := json.Marshal(map[string]interface{}{
+		"version":        2,
+		"source":         source,
+		"last_refreshed": lastRefresh,
+		"checksum":       hex.EncodeToString(h.Sum(nil)),
+		"data":           values,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotCorrupt(t *testing.T) {
+	for name, content := range map[string]string{
+		"invalid json":     `{"version": 2, "source": "test", "data": {"key": `,
+		"checksum":         `{"version": 2, "source": "test", "checksum": "abc", "data": {"key": "dmFsdWU="}}`,
+		"version":          `{"version": 99, "source": "test", "data": {"key": "dmFsdWU="}}`,
+		"previous version": `{"version": 1, "source": "test", "data": {"key": "value"}}`,
+		"other source":     `{"version": 2, "source": "other", "data": {"key": "dmFsdWU="}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "snapshot.json")
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			s := cache.NewSource(
+				"test",
+				cache.WithDefaultData(map[string]string{"key": "default"}),
+				cache.WithSnapshot(path),
+			)
+			defer s.Stop()
+
+			item, err := s.Get("key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if item.Value() != "default" {
+				t.Fatal("invalid snapshot should be ignored")
+			}
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package cache
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,11 +26,60 @@ type StoppableSource interface {
 
 	// Stop is used to clean up gorotines that try to fetch new data
 	Stop()
+
+	// Status returns the current refresh state of the source
+	Status() Status
+
+	// Subscribe returns a subscription receiving a diff after every refresh
+	// that changed the data. The subscription is closed on Stop.
+	Subscribe(buffer int) *Subscription
+
+	// OnChange calls f with a diff after every refresh that changed the data.
+	// The returned function cancels the subscription.
+	OnChange(f func(Diff)) func()
+
+	// Refresh fetches new data immediately and returns the result. Concurrent
+	// calls share a single fetch and the next scheduled refresh is moved
+	// relative to it.
+	Refresh(ctx context.Context) error
+
+	// WaitReady blocks until the source has data to serve or ctx is done
+	WaitReady(ctx context.Context) error
+
+	// Watch returns a channel receiving the item whenever the value of key
+	// changes, or nil when the key is removed
+	Watch(ctx context.Context, key string) <-chan Item
 }
 
 // FetchFunc is a function that should be used by dynamic source to refresh
-// data.
-type FetchFunc func() (map[string]string, error)
+// data. The context is cancelled when the fetch times out or when the source
+// is stopped.
+type FetchFunc func(ctx context.Context) (map[string]string, error)
+
+// WrapFetchFunc adapts a fetch function that doesn't accept a context. The
+// wrapped function can't be interrupted, so on cancellation the source stops
+// waiting for it and the result is discarded once it returns.
+func WrapFetchFunc(f func() (map[string]string, error)) FetchFunc {
+	type result struct {
+		data map[string]string
+		err  error
+	}
+
+	return func(ctx context.Context) (map[string]string, error) {
+		resCh := make(chan result, 1)
+		go func() {
+			data, err := f()
+			resCh <- result{data, err}
+		}()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case res := <-resCh:
+			return res.data, res.err
+		}
+	}
+}
 
 // Option is a function that sets an option for a source
 type Option func(*Options)
@@ -34,26 +87,116 @@ type Option func(*Options)
 // Options for configuring source of cached data. The options shouldn't be
 // used directly but through With... functions.
 type Options struct {
-	DefaultData      map[string]string
-	LastRefreshed    time.Time
-	FetchFunc        FetchFunc
-	RefreshFrequency time.Duration
-	RetryWait        time.Duration
+	DefaultData        map[string]string
+	LastRefreshed      time.Time
+	FetchFunc          FetchFunc
+	FetchTimeout       time.Duration
+	RefreshFrequency   time.Duration
+	RetryPolicy        RetryPolicy
+	RefreshHook        RefreshHook
+	Logger             Logger
+	Metrics            Metrics
+	SnapshotPath       string
+	InitialLoadTimeout time.Duration
+	LoadFunc           LoadFunc
+	LoadTTL            time.Duration
+	NegativeTTL        time.Duration
+	MaxLoadedKeys      int
+	MaxLoadedBytes     int64
+	EvictionPolicy     EvictionPolicy
+	EvictionFunc       EvictionFunc
+	BatchLoadFunc      BatchLoadFunc
+	BatchWindow        time.Duration
+	MaxBatchSize       int
+	Revalidate         bool
+	RevalidateAhead    time.Duration
+	MaxStaleness       time.Duration
+	StalenessHook      StalenessHook
+	Scheduler          *Scheduler
+	Backend            string
+	RefreshJitter      time.Duration
+	InitialDelay       time.Duration
+	AlignRefresh       bool
+	AlignOffset        time.Duration
+	Schedule           Schedule
 }
 
-type source struct {
+// dataSnapshot is an immutable version of source data along with metadata
+// of the refresh that produced it. Snapshots are swapped atomically, so Get
+// never waits for a refresh and items are consistent with their data.
+type dataSnapshot struct {
 	metadata
+	data map[string]string
 
-	name string
+	// ready is set once the source has data to serve
+	ready bool
+}
 
-	data map[string]string
-	lock sync.RWMutex
+type source struct {
+	notifier
+
+	name string
 
-	fetchFunc        FetchFunc
-	refreshFrequency time.Duration
-	retryWait        time.Duration
-	stopCh           chan struct{}
-	stoppedCh        chan struct{}
+	// current data snapshot, replaced only with lock held
+	current atomic.Pointer[dataSnapshot]
+	lock    sync.RWMutex
+
+	// readyCh is closed once the source has data to serve
+	readyCh   chan struct{}
+	readyOnce sync.Once
+
+	// Refresh status, guarded by lock
+	lastAttempt         time.Time
+	lastError           error
+	consecutiveFailures int
+	lastFetchDuration   time.Duration
+
+	fetchFunc      FetchFunc
+	fetchTimeout   time.Duration
+	retryPolicy    RetryPolicy
+	refreshHook    RefreshHook
+	logger         Logger
+	metrics        Metrics
+	snapshotPath   string
+	snapshotLoaded bool
+
+	// refreshSchedule decides when data is due, scheduled refreshes are
+	// delayed randomly by up to refreshJitter and the first one also by up
+	// to initialDelay
+	refreshSchedule Schedule
+	refreshJitter   time.Duration
+	initialDelay    time.Duration
+
+	// revalidate enables refreshes triggered by Get of data that is stale
+	// or will be within revalidateAhead
+	revalidate      bool
+	revalidateAhead time.Duration
+
+	// Data older than maxStaleness isn't served, the state is tracked by
+	// updateStaleness under stalenessLock
+	maxStaleness   time.Duration
+	stalenessHook  StalenessHook
+	stalenessLock  sync.Mutex
+	stalenessTimer *time.Timer
+	wasTooStale    bool
+
+	// lazy holds keys loaded on demand in read-through mode
+	lazy *lazyStore
+
+	// inflight is the currently running refresh, guarded by refreshLock
+	refreshLock     sync.Mutex
+	inflight        *refreshCall
+	rescheduleCh    chan struct{}
+	manualRefreshes sync.WaitGroup
+
+	// scheduler runs refreshes instead of the start loop if set
+	scheduler *Scheduler
+	job       *scheduledJob
+
+	// ctx is cancelled by Stop and interrupts any in-flight fetch
+	ctx       context.Context
+	cancel    context.CancelFunc
+	stoppedCh chan struct{}
 }
 
 func (s *source) start() {
@@ -64,58 +207,294 @@ func (s *source) start() {
 		return
 	}
 
-	// Default data hasn't been provided, use initial refresh
-	if len(s.data) == 0 {
-		log.Println("No data provided, initial fetch")
-		s.refresh()
+	// Manual refresh may already run concurrently
+	if !s.snapshot().ready {
+		// Default data hasn't been provided, use initial refresh
+		s.logger.Info("no data provided, initial fetch", "source", s.name)
 	}
 
+	at := s.firstRefresh(time.Now())
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+
 	for {
+		// Schedule without further refreshes only waits for manual ones
+		var timerC <-chan time.Time
+		if !at.Equal(Never) {
+			timerC = timer.C
+		}
+
 		select {
-		case <-s.stopCh:
+		case <-s.ctx.Done():
 			return
-		case <-time.After(s.refreshFrequency):
-			log.Println("Refreshing data for source", s.name)
-			s.refresh()
+		case <-s.rescheduleCh:
+			// Manual refresh finished, schedule next one relative to it
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		case <-timerC:
+			s.logger.Debug("refreshing source", "source", s.name)
+			<-s.coalescedRefresh(false).done
+		}
+
+		if at = s.plannedRefresh(time.Now()); !at.Equal(Never) {
+			timer.Reset(time.Until(at))
 		}
 	}
 }
 
-func (s *source) refresh() {
-	var data map[string]string
-	var err error
-	var refreshTime time.Time
+// refresh fetches new data, retrying according to the retry policy, and
+// returns the final error. It must not be called concurrently, use
+// coalescedRefresh instead.
+func (s *source) refresh() error {
+	start := time.Now()
 
-	// Super simple retry that should be converted to a configurable
-	// retry with backoff?
-	for i := 0; i < 3; i++ {
-		data, err = s.fetchFunc()
-		refreshTime = time.Now()
-		if err != nil {
-			// Log error
-			// s.logger.Error()
-			log.Println("Failed to fetch data source. Error:", err)
+	for attempt := 1; ; attempt++ {
+		fetchStart := time.Now()
+		data, err := s.fetch()
+		refreshTime := time.Now()
 
-			// We've reached end of retry
-			if i == 2 {
-				return
-			}
+		// Source was stopped during the fetch, the result is irrelevant
+		if s.ctx.Err() != nil {
+			return ErrStopped
+		}
+
+		event := RefreshEvent{
+			Source:   s.name,
+			Attempt:  attempt,
+			Duration: refreshTime.Sub(fetchStart),
+			Err:      err,
+		}
+
+		// Data is only replaced by refresh, which doesn't run concurrently,
+		// so it can be compared without holding the lock
+		var oldData map[string]string
+		changed := false
+		if err == nil {
+			oldData = s.snapshot().data
+			changed = s.dataChanged(oldData, data)
+		}
+
+		s.lock.Lock()
+		s.lastAttempt = refreshTime
+		s.lastFetchDuration = event.Duration
+		if err == nil {
+			s.adaptSchedule(changed, nil)
+			s.setData(data, refreshTime, true)
+			s.lastError = nil
+			s.consecutiveFailures = 0
+			s.markReady()
+		} else {
+			s.lastError = err
+			s.consecutiveFailures++
+		}
+		s.lock.Unlock()
+
+		s.metrics.FetchAttempt(s.name, event.Duration, err)
+
+		if err == nil {
+			s.writeSnapshot(data, refreshTime)
+			s.publishChanges(oldData, data, refreshTime)
+			s.metrics.SourceUpdated(s.name, len(data), refreshTime)
+			s.logger.Debug(
+				"source refreshed",
+				"source", s.name,
+				"attempt", attempt,
+				"duration", event.Duration,
+				"keys", len(data),
+			)
+
+			s.updateStaleness()
+
+			event.Done = true
+			s.notify(event)
+			return nil
+		}
+
+		var wait time.Duration
+		retry := false
+		if !IsPermanent(err) {
+			wait, retry = s.retryPolicy.Next(attempt, time.Since(start))
+		}
 
-			<-time.After(s.retryWait)
-			continue
+		if !retry {
+			s.adaptSchedule(false, err)
+			s.logger.Error(
+				"source refresh failed",
+				"source", s.name,
+				"attempt", attempt,
+				"duration", event.Duration,
+				"error", err,
+			)
+
+			event.Done = true
+			s.notify(event)
+			return err
+		}
+
+		s.logger.Warn(
+			"fetch failed, retrying",
+			"source", s.name,
+			"attempt", attempt,
+			"duration", event.Duration,
+			"retry_in", wait,
+			"error", err,
+		)
+
+		event.Wait = wait
+		s.notify(event)
+
+		select {
+		case <-s.ctx.Done():
+			return ErrStopped
+		case <-time.After(wait):
 		}
 	}
+}
 
-	s.lock.Lock()
-	defer s.lock.Unlock()
-	s.data = data
-	s.lastRefresh = refreshTime
-	s.nextRefresh = refreshTime.Add(s.refreshFrequency)
+// writeSnapshot persists refreshed data if snapshot path is configured
+func (s *source) writeSnapshot(data map[string]string, refreshTime time.Time) {
+	if s.snapshotPath == "" {
+		return
+	}
+
+	if err := saveSnapshot(s.snapshotPath, s.name, data, refreshTime); err != nil {
+		s.logger.Warn(
+			"failed to write snapshot",
+			"source", s.name,
+			"path", s.snapshotPath,
+			"error", err,
+		)
+	}
 }
 
+// loadSnapshot replaces default data with persisted snapshot if available
+func (s *source) loadSnapshot() {
+	data, lastRefresh, err := loadSnapshot(s.snapshotPath, s.name)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			s.logger.Warn(
+				"ignoring snapshot",
+				"source", s.name,
+				"path", s.snapshotPath,
+				"error", err,
+			)
+		}
+		return
+	}
+
+	s.logger.Info(
+		"loaded snapshot",
+		"source", s.name,
+		"path", s.snapshotPath,
+		"keys", len(data),
+		"last_refreshed", lastRefresh,
+	)
+
+	s.setData(data, lastRefresh, true)
+	s.snapshotLoaded = true
+}
+
+// publishChanges notifies subscribers about keys changed by a refresh
+func (s *source) publishChanges(old, new map[string]string, refreshTime time.Time) {
+	if !s.hasSubscribers() {
+		return
+	}
+
+	d := diffData(old, new)
+	if d.Empty() {
+		return
+	}
+
+	d.Source = s.name
+	d.LastRefreshed = refreshTime
+	s.publish(d)
+}
+
+func (s *source) notify(event RefreshEvent) {
+	if s.refreshHook != nil {
+		s.refreshHook(event)
+	}
+}
+
+// fetch invokes the fetch function with a context bound to the source
+// lifetime and optional fetch timeout.
+func (s *source) fetch() (map[string]string, error) {
+	ctx, cancel := s.fetchContext()
+	defer cancel()
+
+	return s.fetchFunc(ctx)
+}
+
+// fetchContext returns a context for calls to the backend, it's canceled
+// when the source stops or the fetch timeout expires
+func (s *source) fetchContext() (context.Context, context.CancelFunc) {
+	if s.fetchTimeout > 0 {
+		return context.WithTimeout(s.ctx, s.fetchTimeout)
+	}
+	return context.WithCancel(s.ctx)
+}
+
+// Stop cancels any in-flight fetch, waits for refresh goroutines to finish
+// and closes change subscriptions. It is safe to call Stop multiple times.
 func (s *source) Stop() {
-	close(s.stopCh)
-	<-s.stoppedCh
+	s.refreshLock.Lock()
+	s.cancel()
+	s.refreshLock.Unlock()
+
+	if s.job != nil {
+		s.scheduler.remove(s.job)
+	} else if s.scheduler == nil {
+		<-s.stoppedCh
+	}
+	s.manualRefreshes.Wait()
+	s.stopStaleness()
+	s.notifier.close()
+}
+
+// rawData returns the current dataset, the map must not be modified
+func (s *source) rawData() map[string]string {
+	return s.snapshot().data
+}
+
+// snapshot returns the current data snapshot
+func (s *source) snapshot() *dataSnapshot {
+	return s.current.Load()
+}
+
+// setData replaces the data snapshot, it must be called with lock held
+func (s *source) setData(data map[string]string, lastRefresh time.Time, ready bool) {
+	s.current.Store(&dataSnapshot{
+		metadata: NewMetadata(lastRefresh, s.nextRefreshAfter(lastRefresh)),
+		data:     data,
+		ready:    ready,
+	})
+}
+
+// Status returns the current refresh state of the source
+func (s *source) Status() Status {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	snap := s.snapshot()
+	st := Status{
+		Name:                s.name,
+		LastAttempt:         s.lastAttempt,
+		LastRefreshed:       snap.lastRefresh,
+		LastError:           s.lastError,
+		ConsecutiveFailures: s.consecutiveFailures,
+		LastFetchDuration:   s.lastFetchDuration,
+		RefreshInterval:     s.refreshInterval(),
+		Keys:                len(snap.data),
+		TooStale:            s.tooStale(snap, time.Now()),
+	}
+	if s.lazy != nil {
+		st.LoadedKeys, st.LoadedBytes, st.Evictions = s.lazy.stats()
+	}
+	return st
 }
 
 func (s *source) Name() string {
@@ -123,58 +502,149 @@ func (s *source) Name() string {
 }
 
 func (s *source) Get(key string) (value Item, err error) {
-	s.lock.RLock()
-	defer s.lock.RUnlock()
+	item, err := s.getData(key)
+	if s.revalidate && err != ErrNotReady {
+		s.revalidateStale()
+	}
+	if err != ErrKeyNotFound || s.lazy == nil {
+		s.metrics.Lookup(s.name, err == nil)
+		return item, err
+	}
 
-	v, ok := s.data[key]
+	// Read-through mode, load keys missing in the bulk data
+	item, loaded, err := s.lazy.get(s, key)
+	s.metrics.Lookup(s.name, !loaded && err == nil)
+	return item, err
+}
+
+// getData returns an item from data fetched by refresh. It doesn't take
+// any lock, the item is built from a single snapshot.
+func (s *source) getData(key string) (value Item, err error) {
+	snap := s.snapshot()
+	if !snap.ready {
+		return nil, ErrNotReady
+	}
 
+	v, ok := snap.data[key]
 	if !ok {
 		return nil, ErrKeyNotFound
 	}
 
-	var nextRefresh time.Time
-	if s.refreshFrequency > 0 {
-		nextRefresh = s.NextRefresh()
-	} else {
-		nextRefresh = Never
+	item := &item{metadata: snap.metadata, value: v}
+	if s.tooStale(snap, time.Now()) {
+		return item, ErrDataTooStale
 	}
+	return item, nil
+}
 
-	return NewItem(v, s.LastRefreshed(), nextRefresh), nil
+// LastRefreshed returns the time of the current data refresh
+func (s *source) LastRefreshed() time.Time {
+	return s.snapshot().LastRefreshed()
 }
 
+// NextRefresh returns the time when the current data should be refreshed
 func (s *source) NextRefresh() time.Time {
-	s.lock.RLock()
-	defer s.lock.RUnlock()
+	return s.snapshot().NextRefresh()
+}
 
-	return s.LastRefreshed().Add(s.refreshFrequency)
+// IsStale returns true if the current data should have been refreshed
+func (s *source) IsStale() bool {
+	return s.snapshot().IsStale()
 }
 
 // NewSource creates a cache source
 func NewSource(name string, opts ...Option) StoppableSource {
+	s, _ := newSource(name, opts...)
+	return s
+}
+
+// NewSourceE is like NewSource but returns an error if a source created with
+// WithInitialLoadTimeout doesn't load its data in time. The source is stopped
+// in that case.
+func NewSourceE(name string, opts ...Option) (StoppableSource, error) {
+	s, err := newSource(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newSource creates a cache source, the error is only returned if the initial
+// load failed and the stopped source is returned with it
+func newSource(name string, opts ...Option) (*source, error) {
 	o := &Options{
 		DefaultData:   map[string]string{},
 		LastRefreshed: Never,
-		RetryWait:     1 * time.Second,
+		RetryPolicy:   ConstantRetry(1*time.Second, 3),
+		BatchWindow:   DefaultBatchWindow,
+		Logger:        SlogLogger(nil),
+		Metrics:       NopMetrics(),
 	}
 
 	for _, opt := range opts {
 		opt(o)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+
 	s := &source{
-		metadata: metadata{
-			lastRefresh: o.LastRefreshed,
-		},
-		name:             name,
-		data:             o.DefaultData,
-		fetchFunc:        o.FetchFunc,
-		refreshFrequency: o.RefreshFrequency,
-		stopCh:           make(chan struct{}),
-		stoppedCh:        make(chan struct{}),
-	}
-
-	go s.start()
-	return s
+		name:            name,
+		fetchFunc:       o.FetchFunc,
+		fetchTimeout:    o.FetchTimeout,
+		retryPolicy:     o.RetryPolicy,
+		refreshHook:     o.RefreshHook,
+		logger:          o.Logger,
+		metrics:         o.Metrics,
+		snapshotPath:    o.SnapshotPath,
+		revalidate:      o.Revalidate,
+		revalidateAhead: o.RevalidateAhead,
+		maxStaleness:    o.MaxStaleness,
+		stalenessHook:   o.StalenessHook,
+		ctx:             ctx,
+		cancel:          cancel,
+		stoppedCh:       make(chan struct{}),
+		readyCh:         make(chan struct{}),
+		rescheduleCh:    make(chan struct{}, 1),
+		scheduler:       o.Scheduler,
+		refreshJitter:   o.RefreshJitter,
+		initialDelay:    o.InitialDelay,
+		refreshSchedule: newRefreshSchedule(o),
+	}
+
+	if o.LoadFunc != nil || o.BatchLoadFunc != nil {
+		s.lazy = newLazyStore(o)
+	}
+
+	ready := s.fetchFunc == nil || len(o.DefaultData) > 0
+	s.setData(o.DefaultData, o.LastRefreshed, ready)
+
+	if s.snapshotPath != "" {
+		s.loadSnapshot()
+	}
+
+	snap := s.snapshot()
+	s.metrics.SourceUpdated(name, len(snap.data), snap.lastRefresh)
+
+	if snap.ready {
+		s.markReady()
+	}
+
+	s.updateStaleness()
+	if s.scheduler != nil {
+		s.schedule(o.Backend)
+	} else {
+		go s.start()
+	}
+
+	if o.InitialLoadTimeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), o.InitialLoadTimeout)
+		defer cancel()
+		if err := s.waitInitialLoad(ctx); err != nil {
+			return s, fmt.Errorf("source %s: %w", name, err)
+		}
+	}
+
+	return s, nil
 }
 
 // WithDefaultData provides a default cached data for a source
@@ -192,11 +662,191 @@ func WithLastRefreshTime(t time.Time) Option {
 	}
 }
 
-// WithLogger provides a custom logger interface
-// func WithLogger()
+// WithLogger provides a custom logger for the source. By default messages
+// are logged with slog.Default(), use NopLogger() to silence them.
+func WithLogger(l Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
+// WithMetrics sets a metrics collector for the source
+func WithMetrics(m Metrics) Option {
+	return func(o *Options) {
+		o.Metrics = m
+	}
+}
+
+// WithSnapshot persists data of every successful refresh to a file at path
+// and loads it on start, so the source can serve data before the first fetch
+// succeeds. Loaded data keeps its original refresh time and replaces default
+// data. Corrupt or incompatible snapshots are ignored.
+func WithSnapshot(path string) Option {
+	return func(o *Options) {
+		o.SnapshotPath = path
+	}
+}
+
+// WithInitialLoadTimeout makes the source constructor block until the first
+// fetch succeeds. If the source isn't ready within d it's stopped and
+// NewSourceE returns an error wrapping ErrNotReady. Constructors that don't
+// return an error return the stopped source, WaitReady then returns the
+// error:
+//
+//	s, err := NewDbSourceE(..., WithInitialLoadTimeout(10*time.Second))
+//	if err != nil {
+//		...
+//	}
+func WithInitialLoadTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.InitialLoadTimeout = d
+	}
+}
+
+// WithStaleWhileRevalidate makes a Get of stale data, or data that becomes
+// stale within refreshAhead, start a background refresh. The Get returns the
+// current data without waiting and at most one refresh runs at a time. Only
+// the first Get after data enters the window triggers a refresh, if it fails
+// the source waits for the scheduled refresh.
+func WithStaleWhileRevalidate(refreshAhead time.Duration) Option {
+	return func(o *Options) {
+		o.Revalidate = true
+		o.RevalidateAhead = refreshAhead
+	}
+}
+
+// WithMaxStaleness stops serving data that wasn't refreshed for longer than
+// d. Get then returns ErrDataTooStale along with the stale item, so callers
+// that can tolerate old data may still use it. Data without refresh time,
+// e.g. default data without WithLastRefreshTime, isn't checked.
+func WithMaxStaleness(d time.Duration) Option {
+	return func(o *Options) {
+		o.MaxStaleness = d
+	}
+}
+
+// WithStalenessHook sets a function called when source data exceeds the
+// maximum staleness and when it's refreshed again
+func WithStalenessHook(f StalenessHook) Option {
+	return func(o *Options) {
+		o.StalenessHook = f
+	}
+}
+
+// WithScheduler runs refreshes of the source by a shared scheduler instead
+// of a goroutine owned by the source
+func WithScheduler(s *Scheduler) Option {
+	return func(o *Options) {
+		o.Scheduler = s
+	}
+}
+
+// WithBackend names the backend the source fetches from, so the scheduler
+// can limit concurrent refreshes per backend using WithBackendLimit
+func WithBackend(name string) Option {
+	return func(o *Options) {
+		o.Backend = name
+	}
+}
+
+// WithRefreshJitter delays each scheduled refresh by a random duration up to
+// max, so sources of many instances started together don't refresh at the
+// same time. Item NextRefresh doesn't include the jitter.
+func WithRefreshJitter(max time.Duration) Option {
+	return func(o *Options) {
+		o.RefreshJitter = max
+	}
+}
+
+// WithInitialDelay delays the first refresh after the source starts by a
+// random duration up to max, including the initial fetch of a source without
+// data
+func WithInitialDelay(max time.Duration) Option {
+	return func(o *Options) {
+		o.InitialDelay = max
+	}
+}
+
+// WithAlignedRefresh schedules refreshes on wall-clock boundaries of the
+// refresh frequency shifted by offset, instead of a full period after the
+// previous refresh. E.g. a minute frequency refreshes on every :00, so
+// instances agree on data versions. See Aligned.
+func WithAlignedRefresh(offset time.Duration) Option {
+	return func(o *Options) {
+		o.AlignRefresh = true
+		o.AlignOffset = offset
+	}
+}
+
+// WithLoadFunc enables read-through mode. A Get of a key missing in the data
+// fetched by FetchFunc loads the key using f and keeps it for ttl, zero ttl
+// keeps the key forever. Concurrent Get calls of the same key share a single
+// load. If reloading an expired key fails the expired value is served.
+func WithLoadFunc(f LoadFunc, ttl time.Duration) Option {
+	return func(o *Options) {
+		o.LoadFunc = f
+		o.LoadTTL = ttl
+	}
+}
+
+// WithNegativeTTL caches ErrKeyNotFound returned by LoadFunc for d, so
+// missing keys don't hit the backend on every Get
+func WithNegativeTTL(d time.Duration) Option {
+	return func(o *Options) {
+		o.NegativeTTL = d
+	}
+}
+
+// WithBatchLoadFunc enables read-through mode loading missing keys in
+// batches. Keys missed by concurrent Get calls within the batch window are
+// passed to a single call of f and each loaded key is kept for ttl, zero ttl
+// keeps the key forever. It takes precedence over WithLoadFunc.
+func WithBatchLoadFunc(f BatchLoadFunc, ttl time.Duration) Option {
+	return func(o *Options) {
+		o.BatchLoadFunc = f
+		o.LoadTTL = ttl
+	}
+}
+
+// WithBatchWindow overrides DefaultBatchWindow, the time a batch waits for
+// more keys, and limits the number of keys in a batch. A full batch is loaded
+// immediately, zero maxSize doesn't limit the batch size.
+func WithBatchWindow(window time.Duration, maxSize int) Option {
+	return func(o *Options) {
+		o.BatchWindow = window
+		o.MaxBatchSize = maxSize
+	}
+}
+
+// WithLoadLimits bounds keys loaded in read-through mode to maxKeys entries
+// and maxBytes bytes of keys and values, zero disables a limit. Keys over the
+// limits are evicted according to the eviction policy, LRU by default. Data
+// fetched by FetchFunc isn't limited.
+func WithLoadLimits(maxKeys int, maxBytes int64) Option {
+	return func(o *Options) {
+		o.MaxLoadedKeys = maxKeys
+		o.MaxLoadedBytes = maxBytes
+	}
+}
+
+// WithEvictionPolicy sets the policy choosing keys evicted when read-through
+// limits are reached. Each source needs its own policy instance.
+func WithEvictionPolicy(p EvictionPolicy) Option {
+	return func(o *Options) {
+		o.EvictionPolicy = p
+	}
+}
+
+// WithEvictionFunc sets a callback called with keys evicted from read-through
+// cache
+func WithEvictionFunc(f EvictionFunc) Option {
+	return func(o *Options) {
+		o.EvictionFunc = f
+	}
+}
 
 // WithFetchFunc sets a refresh function and frequency in which should be
-// function invoked.
+// function invoked. Zero frequency only fetches the initial data.
 func WithFetchFunc(f FetchFunc, freq time.Duration) Option {
 	return func(o *Options) {
 		o.FetchFunc = f
@@ -204,11 +854,49 @@ func WithFetchFunc(f FetchFunc, freq time.Duration) Option {
 	}
 }
 
+// WithSchedule refreshes the source according to s instead of the frequency
+// set by WithFetchFunc. Item NextRefresh returns the next time of the
+// schedule.
+//
+//	cache.NewSource(
+//		"rates",
+//		cache.WithFetchFunc(fetchRates, 0),
+//		cache.WithSchedule(cache.MustCron("0 * * * *", time.UTC)),
+//	)
+func WithSchedule(s Schedule) Option {
+	return func(o *Options) {
+		o.Schedule = s
+	}
+}
+
+// WithFetchTimeout limits the duration of a single fetch function call. The
+// context passed to the fetch function is cancelled once the timeout elapses.
+func WithFetchTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.FetchTimeout = d
+	}
+}
+
 // WithRetryWait overrides default 1 second retry wait period when fetch
-// function returns an error
+// function returns an error. The fetch is attempted at most 3 times.
 func WithRetryWait(d time.Duration) Option {
 	return func(o *Options) {
-		o.RetryWait = d
+		o.RetryPolicy = ConstantRetry(d, 3)
+	}
+}
+
+// WithRetryPolicy sets a policy used to retry failed fetches
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(o *Options) {
+		o.RetryPolicy = p
+	}
+}
+
+// WithRefreshHook sets a function that is notified about every fetch attempt
+// and the final outcome of each refresh
+func WithRefreshHook(h RefreshHook) Option {
+	return func(o *Options) {
+		o.RefreshHook = h
 	}
 }
 
@@ -1,7 +1,9 @@
 package cache_test
 
 import (
+	"context"
 	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -87,9 +89,98 @@ func BenchmarkStaticSourceGetParallel(b *testing.B) {
 	})
 }
 
+func BenchmarkStaticSourceGetParallelism(b *testing.B) {
+	s := cache.NewStaticSource(
+		"test",
+		map[string]string{
+			"key":  "value",
+			"key2": "value2",
+		},
+		time.Now(),
+	)
+
+	for _, p := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("goroutines-per-cpu-%d", p), func(b *testing.B) {
+			b.SetParallelism(p)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					s.Get("key")
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkSourceGetParallelDuringRefresh(b *testing.B) {
+	data := map[string]string{}
+	for i := 0; i < 1000; i++ {
+		data[fmt.Sprint("key", i)] = "value"
+	}
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		return data, nil
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, time.Millisecond),
+		cache.WithLogger(cache.NopLogger()),
+	)
+	defer s.Stop()
+	s.WaitReady(context.Background())
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.Get("key1")
+		}
+	})
+}
+
+func TestGetDuringRefresh(t *testing.T) {
+	var version int32
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		v := atomic.AddInt32(&version, 1)
+		return map[string]string{"key": fmt.Sprint(v)}, nil
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, time.Millisecond),
+	)
+	defer s.Stop()
+	s.WaitReady(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			s.Refresh(context.Background())
+		}
+	}()
+
+	last := 0
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		item, err := s.Get("key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, _ := item.Int()
+		if v < last {
+			t.Fatal("get should never return older data, got:", v, last)
+		}
+		last = v
+	}
+}
+
 func TestRefresh(t *testing.T) {
 	refreshRan := false
-	fetchFunc := func() (map[string]string, error) {
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
 		refreshRan = true
 		m := map[string]string{
 			"key": "refreshed",
@@ -133,22 +224,176 @@ func TestRefresh(t *testing.T) {
 }
 
 func TestRefreshError(t *testing.T) {
-	refreshCount := 0
-	fetchFunc := func() (map[string]string, error) {
-		refreshCount += 1
+	var refreshCount int32
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		atomic.AddInt32(&refreshCount, 1)
 		return nil, fmt.Errorf("error")
 	}
 
 	s := cache.NewSource(
 		"test",
-		cache.WithFetchFunc(fetchFunc, 10*time.Millisecond),
-		cache.WithRetryWait(10*time.Millisecond),
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+		cache.WithRetryWait(time.Millisecond),
+	)
+	defer s.Stop()
+
+	<-time.After(50 * time.Millisecond)
+
+	if c := atomic.LoadInt32(&refreshCount); c != 3 {
+		t.Fatal("refresh should be attempted 3 times. Actual attempts: ", c)
+	}
+}
+
+func TestRefreshStopsRetryingOnSuccess(t *testing.T) {
+	var refreshCount int32
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		if atomic.AddInt32(&refreshCount, 1) == 1 {
+			return nil, fmt.Errorf("error")
+		}
+		return map[string]string{"key": "value"}, nil
+	}
+
+	events := make(chan cache.RefreshEvent, 10)
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+		cache.WithRetryWait(time.Millisecond),
+		cache.WithRefreshHook(func(e cache.RefreshEvent) {
+			events <- e
+		}),
 	)
 	defer s.Stop()
 
-	<-time.After(5 * time.Millisecond)
+	e := <-events
+	if e.Err == nil || e.Done || e.Attempt != 1 || e.Wait != time.Millisecond {
+		t.Fatalf("unexpected first attempt event: %+v", e)
+	}
+
+	e = <-events
+	if e.Err != nil || !e.Done || e.Attempt != 2 || e.Source != "test" {
+		t.Fatalf("unexpected final event: %+v", e)
+	}
+
+	<-time.After(10 * time.Millisecond)
+	if c := atomic.LoadInt32(&refreshCount); c != 2 {
+		t.Fatal("successful fetch shouldn't be retried. Actual fetches: ", c)
+	}
+
+	item, err := s.Get("key")
+	if err != nil || item.Value() != "value" {
+		t.Fatal("refreshed value should be served")
+	}
+}
+
+func TestRefreshPermanentError(t *testing.T) {
+	var refreshCount int32
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		atomic.AddInt32(&refreshCount, 1)
+		return nil, cache.Permanent(fmt.Errorf("bad query"))
+	}
+
+	events := make(chan cache.RefreshEvent, 10)
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+		cache.WithRetryWait(time.Millisecond),
+		cache.WithRefreshHook(func(e cache.RefreshEvent) {
+			events <- e
+		}),
+	)
+	defer s.Stop()
+
+	e := <-events
+	if !e.Done || !cache.IsPermanent(e.Err) {
+		t.Fatalf("permanent error should end the refresh: %+v", e)
+	}
+
+	<-time.After(10 * time.Millisecond)
+	if c := atomic.LoadInt32(&refreshCount); c != 1 {
+		t.Fatal("permanent error shouldn't be retried. Actual fetches: ", c)
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	deadlineSet := make(chan bool, 1)
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		_, ok := ctx.Deadline()
+		select {
+		case deadlineSet <- ok:
+		default:
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+		cache.WithFetchTimeout(5*time.Millisecond),
+		cache.WithRetryWait(time.Millisecond),
+	)
+	defer s.Stop()
+
+	select {
+	case ok := <-deadlineSet:
+		if !ok {
+			t.Fatal("fetch context should have a deadline")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("fetch function wasn't called")
+	}
+}
+
+func TestStopCancelsFetch(t *testing.T) {
+	started := make(chan struct{})
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+	)
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop should cancel in-flight fetch")
+	}
+}
+
+func TestWrapFetchFunc(t *testing.T) {
+	f := cache.WrapFetchFunc(func() (map[string]string, error) {
+		return map[string]string{"key": "value"}, nil
+	})
+
+	data, err := f(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["key"] != "value" {
+		t.Fatal("wrapped fetch function returned wrong data")
+	}
+
+	block := make(chan struct{})
+	defer close(block)
+	f = cache.WrapFetchFunc(func() (map[string]string, error) {
+		<-block
+		return nil, nil
+	})
 
-	if refreshCount != 3 {
-		t.Fatal("refresh should be retried at least 3 time. Actual retries: ", refreshCount)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f(ctx); err != context.Canceled {
+		t.Fatal("wrapped fetch function should return on cancelled context")
 	}
 }
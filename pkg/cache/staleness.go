@@ -0,0 +1,80 @@
+package cache
+
+import "time"
+
+// StalenessHook is called when source data becomes older than the maximum
+// staleness set by WithMaxStaleness and again when a refresh brings it back
+// within the bound. It should return quickly.
+type StalenessHook func(source string, tooStale bool, lastRefreshed time.Time)
+
+// tooStale returns true if snapshot data is older than maxStaleness. Data
+// without refresh time isn't checked.
+func (s *source) tooStale(snap *dataSnapshot, now time.Time) bool {
+	if s.maxStaleness <= 0 || snap.lastRefresh.IsZero() {
+		return false
+	}
+	return now.Sub(snap.lastRefresh) > s.maxStaleness
+}
+
+// updateStaleness reports a change of the too stale state and schedules the
+// next check at the time data exceeds the maximum staleness. It's called
+// after each successful refresh and by the staleness timer.
+func (s *source) updateStaleness() {
+	if s.maxStaleness <= 0 {
+		return
+	}
+
+	s.stalenessLock.Lock()
+	defer s.stalenessLock.Unlock()
+
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	now := time.Now()
+	snap := s.snapshot()
+	tooStale := s.tooStale(snap, now)
+	lastRefresh := snap.lastRefresh
+
+	if s.stalenessTimer != nil {
+		s.stalenessTimer.Stop()
+		s.stalenessTimer = nil
+	}
+	if !tooStale && !lastRefresh.IsZero() {
+		s.stalenessTimer = time.AfterFunc(
+			lastRefresh.Add(s.maxStaleness).Sub(now),
+			s.updateStaleness,
+		)
+	}
+
+	if tooStale == s.wasTooStale {
+		return
+	}
+	s.wasTooStale = tooStale
+
+	if tooStale {
+		s.logger.Warn(
+			"source data is too stale",
+			"source", s.name,
+			"last_refreshed", lastRefresh,
+			"max_staleness", s.maxStaleness,
+		)
+	} else {
+		s.logger.Info("source data is within max staleness", "source", s.name)
+	}
+
+	if s.stalenessHook != nil {
+		s.stalenessHook(s.name, tooStale, lastRefresh)
+	}
+}
+
+// stopStaleness stops the staleness timer of a stopped source
+func (s *source) stopStaleness() {
+	s.stalenessLock.Lock()
+	defer s.stalenessLock.Unlock()
+
+	if s.stalenessTimer != nil {
+		s.stalenessTimer.Stop()
+		s.stalenessTimer = nil
+	}
+}
@@ -0,0 +1,103 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+func stalenessEvents() (cache.StalenessHook, <-chan bool) {
+	events := make(chan bool, 10)
+	return func(source string, tooStale bool, lastRefreshed time.Time) {
+		events <- tooStale
+	}, events
+}
+
+func expectStaleness(t *testing.T, events <-chan bool, tooStale bool) {
+	t.Helper()
+
+	select {
+	case e := <-events:
+		if e != tooStale {
+			t.Fatal("unexpected staleness event:", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("staleness hook wasn't called")
+	}
+}
+
+func TestMaxStaleness(t *testing.T) {
+	hook, events := stalenessEvents()
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		return map[string]string{"key": "new"}, nil
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithDefaultData(map[string]string{"key": "old"}),
+		cache.WithLastRefreshTime(time.Now().Add(-2*time.Hour)),
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+		cache.WithMaxStaleness(time.Hour),
+		cache.WithStalenessHook(hook),
+	)
+	defer s.Stop()
+
+	expectStaleness(t, events, true)
+
+	item, err := s.Get("key")
+	if err != cache.ErrDataTooStale {
+		t.Fatal("too stale data should return an error, got:", err)
+	}
+	if item == nil || item.Value() != "old" {
+		t.Fatal("stale item should be returned along with the error")
+	}
+	if !s.Status().TooStale {
+		t.Fatal("status should report too stale data")
+	}
+
+	if err := s.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expectStaleness(t, events, false)
+
+	item, err = s.Get("key")
+	if err != nil || item.Value() != "new" {
+		t.Fatal("refreshed data should be served")
+	}
+	if s.Status().TooStale {
+		t.Fatal("status shouldn't report refreshed data as too stale")
+	}
+}
+
+func TestMaxStalenessExceeded(t *testing.T) {
+	hook, events := stalenessEvents()
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		return map[string]string{"key": "value"}, nil
+	}
+
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, time.Hour),
+		cache.WithMaxStaleness(50*time.Millisecond),
+		cache.WithStalenessHook(hook),
+	)
+	defer s.Stop()
+
+	if err := s.WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	expectStaleness(t, events, true)
+
+	if _, err := s.Get("key"); err != cache.ErrDataTooStale {
+		t.Fatal("data should be too stale after max staleness, got:", err)
+	}
+
+	c := cache.New(s)
+	v, err := c.GetString("test", "key", "default")
+	if err != cache.ErrDataTooStale || v != "default" {
+		t.Fatal("typed getter should return default with the error, got:", v, err)
+	}
+}
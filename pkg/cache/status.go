@@ -0,0 +1,58 @@
+package cache
+
+import "time"
+
+// Status describes the refresh state of a source. It can be used to explain
+// why a source serves stale data.
+type Status struct {
+	// Name of the source
+	Name string
+
+	// LastAttempt is the time when the last fetch attempt finished
+	LastAttempt time.Time
+
+	// LastRefreshed is the time of the last successful refresh
+	LastRefreshed time.Time
+
+	// LastError is the error returned by the last failed fetch attempt. It's
+	// reset to nil by a successful refresh.
+	LastError error
+
+	// ConsecutiveFailures counts failed fetch attempts since the last
+	// successful refresh
+	ConsecutiveFailures int
+
+	// LastFetchDuration is the duration of the last fetch attempt
+	LastFetchDuration time.Duration
+
+	// RefreshInterval is the current interval of the refresh schedule, zero
+	// if the schedule has no fixed interval, e.g. a cron expression
+	RefreshInterval time.Duration
+
+	// Keys is the number of keys currently served by the source
+	Keys int
+
+	// LoadedKeys is the number of keys loaded on demand in read-through mode,
+	// including cached missing keys
+	LoadedKeys int
+
+	// LoadedBytes is the size of keys and values loaded in read-through mode
+	LoadedBytes int64
+
+	// TooStale is true if data is older than the maximum staleness and isn't
+	// served
+	TooStale bool
+
+	// Evictions counts read-through keys evicted to respect the load limits
+	Evictions uint64
+}
+
+// Healthy returns true if the last fetch attempt didn't fail.
+func (s Status) Healthy() bool {
+	return s.ConsecutiveFailures == 0
+}
+
+// statusSource is implemented by sources that can report refresh status
+type statusSource interface {
+	Status() Status
+}
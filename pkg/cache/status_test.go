@@ -0,0 +1,73 @@
+package cache_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+func TestSourceStatus(t *testing.T) {
+	var fail int32 = 1
+	fetchErr := fmt.Errorf("connection refused")
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		if atomic.LoadInt32(&fail) == 1 {
+			return nil, fetchErr
+		}
+		return map[string]string{"key": "value", "key2": "value2"}, nil
+	}
+
+	events := make(chan cache.RefreshEvent, 10)
+	s := cache.NewSource(
+		"test",
+		cache.WithFetchFunc(fetchFunc, 20*time.Millisecond),
+		cache.WithRetryPolicy(cache.ConstantRetry(time.Millisecond, 2)),
+		cache.WithRefreshHook(func(e cache.RefreshEvent) {
+			if e.Done {
+				events <- e
+			}
+		}),
+	)
+	defer s.Stop()
+
+	<-events
+	status := s.Status()
+	if status.Name != "test" {
+		t.Fatal("status should include source name")
+	}
+	if status.LastError != fetchErr {
+		t.Fatal("status should include last fetch error, got:", status.LastError)
+	}
+	if status.ConsecutiveFailures != 2 {
+		t.Fatal("status should count both failed attempts, got:", status.ConsecutiveFailures)
+	}
+	if status.LastAttempt.IsZero() {
+		t.Fatal("last attempt time should be recorded")
+	}
+	if !status.LastRefreshed.Equal(cache.Never) {
+		t.Fatal("source shouldn't be refreshed yet")
+	}
+	if status.Healthy() {
+		t.Fatal("failing source shouldn't be healthy")
+	}
+
+	atomic.StoreInt32(&fail, 0)
+	<-events
+
+	status = s.Status()
+	if status.LastError != nil || status.ConsecutiveFailures != 0 {
+		t.Fatal("successful refresh should reset error status")
+	}
+	if status.Keys != 2 {
+		t.Fatal("status should report number of keys, got:", status.Keys)
+	}
+	if !status.LastRefreshed.Equal(status.LastAttempt) {
+		t.Fatal("last refresh should match last successful attempt")
+	}
+	if !status.Healthy() {
+		t.Fatal("refreshed source should be healthy")
+	}
+}
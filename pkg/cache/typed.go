@@ -0,0 +1,198 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Decoder turns a raw cached value into a typed value
+type Decoder[V any] func(key string, value string) (V, error)
+
+// JSONDecoder returns a Decoder that unmarshals JSON encoded values
+func JSONDecoder[V any]() Decoder[V] {
+	return func(key string, value string) (V, error) {
+		var v V
+		err := json.Unmarshal([]byte(value), &v)
+		return v, err
+	}
+}
+
+// DecodeError is returned by TypedSource for a key whose value couldn't be
+// decoded
+type DecodeError struct {
+	Source string
+	Key    string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode key %q of source %s: %v", e.Key, e.Source, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// TypedItem is a decoded value fetched from cache with metadata about the
+// item
+type TypedItem[V any] interface {
+	Metadata
+
+	Value() V
+}
+
+type typedItem[V any] struct {
+	Metadata
+	value V
+}
+
+func (i *typedItem[V]) Value() V {
+	return i.value
+}
+
+// rawSource is implemented by sources that expose their whole dataset, which
+// allows TypedSource to decode values once per refresh
+type rawSource interface {
+	rawData() map[string]string
+	listen(f func(Diff)) func()
+}
+
+// TypedSource wraps a source and serves values decoded by a Decoder. Values
+// are decoded once when the source data changes, not on every Get. A value
+// that fails to decode is reported for its key only and doesn't affect
+// other keys.
+type TypedSource[V any] struct {
+	source StoppableSource
+	decode Decoder[V]
+
+	lock    sync.RWMutex
+	entries map[string]typedEntry[V]
+
+	unlisten func()
+}
+
+// typedEntry is a decoded value or decode error along with the raw value it
+// was decoded from
+type typedEntry[V any] struct {
+	raw   string
+	value V
+	err   error
+}
+
+// NewTypedSource wraps a source, e.g. created by NewDbSource or
+// NewRedisSource, with a decoder. Stopping the typed source stops the
+// wrapped source.
+func NewTypedSource[V any](source StoppableSource, decode Decoder[V]) *TypedSource[V] {
+	t := &TypedSource[V]{
+		source:   source,
+		decode:   decode,
+		unlisten: func() {},
+	}
+
+	rs, ok := source.(rawSource)
+	if !ok {
+		// Values are decoded on every Get
+		return t
+	}
+
+	// Listen before reading the data so no change is missed. Diffs published
+	// before the data is read are skipped as the data already includes them,
+	// diffs published later wait for the lock and are applied on top of it.
+	t.unlisten = rs.listen(t.apply)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	data := rs.rawData()
+	t.entries = make(map[string]typedEntry[V], len(data))
+	for k, v := range data {
+		t.entries[k] = t.decodeEntry(k, v)
+	}
+
+	return t
+}
+
+func (t *TypedSource[V]) decodeEntry(key, value string) typedEntry[V] {
+	v, err := t.decode(key, value)
+	if err != nil {
+		return typedEntry[V]{raw: value, err: &DecodeError{Source: t.source.Name(), Key: key, Err: err}}
+	}
+	return typedEntry[V]{raw: value, value: v}
+}
+
+func (t *TypedSource[V]) apply(d Diff) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.entries == nil {
+		// Data wasn't read yet and will include the change
+		return
+	}
+
+	for k, v := range d.Added {
+		t.entries[k] = t.decodeEntry(k, v)
+	}
+	for k, c := range d.Modified {
+		t.entries[k] = t.decodeEntry(k, c.New)
+	}
+	for k := range d.Removed {
+		delete(t.entries, k)
+	}
+}
+
+// Name of the wrapped source
+func (t *TypedSource[V]) Name() string {
+	return t.source.Name()
+}
+
+// Source returns the wrapped source serving raw values
+func (t *TypedSource[V]) Source() StoppableSource {
+	return t.source
+}
+
+// Get returns a decoded item. If the raw value of the key couldn't be
+// decoded a *DecodeError is returned.
+func (t *TypedSource[V]) Get(key string) (TypedItem[V], error) {
+	// Too stale data is returned along with the error like by the source
+	raw, err := t.source.Get(key)
+	if err != nil && err != ErrDataTooStale {
+		return nil, err
+	}
+
+	t.lock.RLock()
+	e, ok := t.entries[key]
+	t.lock.RUnlock()
+
+	// The decoded value must come from the same data as the item metadata.
+	// It doesn't if the key was refreshed after the raw item was read or
+	// the wrapped source doesn't expose its data.
+	if !ok || e.raw != raw.Value() {
+		e = t.decodeEntry(key, raw.Value())
+	}
+
+	if e.err != nil {
+		return nil, e.err
+	}
+	return &typedItem[V]{Metadata: raw, value: e.value}, err
+}
+
+// Errors returns decode errors of keys in the current data
+func (t *TypedSource[V]) Errors() map[string]error {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	errs := map[string]error{}
+	for k, e := range t.entries {
+		if e.err != nil {
+			errs[k] = e.err
+		}
+	}
+	return errs
+}
+
+// Stop stops the wrapped source
+func (t *TypedSource[V]) Stop() {
+	t.unlisten()
+	t.source.Stop()
+}
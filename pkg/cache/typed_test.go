@@ -0,0 +1,169 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+type typedConfig struct {
+	Name  string `json:"name"`
+	Limit int    `json:"limit"`
+}
+
+func TestTypedSource(t *testing.T) {
+	fetchFunc, next := versionedFetchFunc(
+		map[string]string{"a": `{"name": "a", "limit": 1}`, "b": `{"name": "b"}`},
+		map[string]string{"a": `{"name": "a", "limit": 2}`, "c": `{"name": "c"}`},
+	)
+
+	s := cache.NewTypedSource(
+		cache.NewSource(
+			"configs",
+			cache.WithDefaultData(map[string]string{
+				"a":      `{"name": "a", "limit": 1}`,
+				"b":      `{"name": "b"}`,
+				"broken": `{"name": `,
+			}),
+			cache.WithFetchFunc(fetchFunc, 5*time.Millisecond),
+		),
+		cache.JSONDecoder[typedConfig](),
+	)
+	defer s.Stop()
+
+	if s.Name() != "configs" {
+		t.Fatal("typed source should use wrapped source name")
+	}
+
+	item, err := s.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Value() != (typedConfig{Name: "a", Limit: 1}) {
+		t.Fatal("wrong decoded value:", item.Value())
+	}
+	if item.LastRefreshed() != cache.Never {
+		t.Fatal("typed item should include metadata")
+	}
+
+	_, err = s.Get("broken")
+	var decodeErr *cache.DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Key != "broken" {
+		t.Fatal("invalid value should return decode error, got:", err)
+	}
+	if _, ok := s.Errors()["broken"]; !ok || len(s.Errors()) != 1 {
+		t.Fatal("decode errors should be reported per key")
+	}
+
+	if _, err := s.Get("missing"); err != cache.ErrKeyNotFound {
+		t.Fatal("missing key should return key not found, got:", err)
+	}
+
+	next()
+	deadline := time.Now().Add(time.Second)
+	for {
+		item, err := s.Get("a")
+		if err == nil && item.Value().Limit == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("refreshed value should be decoded")
+		}
+		<-time.After(5 * time.Millisecond)
+	}
+
+	item, err = s.Get("c")
+	if err != nil || item.Value().Name != "c" {
+		t.Fatal("added key should be decoded")
+	}
+	if _, err := s.Get("b"); err != cache.ErrKeyNotFound {
+		t.Fatal("removed key shouldn't be served")
+	}
+	if len(s.Errors()) != 0 {
+		t.Fatal("decode errors of removed keys should be cleared")
+	}
+}
+
+func TestTypedSourceDecodesOncePerRefresh(t *testing.T) {
+	decodes := 0
+	decoder := func(key, value string) (int, error) {
+		decodes++
+		return strconv.Atoi(value)
+	}
+
+	s := cache.NewTypedSource(
+		cache.NewSource("numbers", cache.WithDefaultData(map[string]string{"key": "42"})),
+		decoder,
+	)
+	defer s.Stop()
+
+	for i := 0; i < 10; i++ {
+		item, err := s.Get("key")
+		if err != nil || item.Value() != 42 {
+			t.Fatal("wrong decoded value")
+		}
+	}
+
+	if decodes != 1 {
+		t.Fatal("value should be decoded once, decoded:", decodes)
+	}
+}
+
+func TestTypedSourceItemConsistency(t *testing.T) {
+	var version int64
+	fetchFunc := func(ctx context.Context) (map[string]string, error) {
+		return map[string]string{"key": strconv.FormatInt(atomic.AddInt64(&version, 1), 10)}, nil
+	}
+
+	src := cache.NewSource("numbers", cache.WithFetchFunc(fetchFunc, time.Millisecond))
+	s := cache.NewTypedSource(src, func(key, value string) (int, error) {
+		return strconv.Atoi(value)
+	})
+	defer s.Stop()
+
+	// Refresh time of every value, published after the data is swapped
+	var lock sync.Mutex
+	refreshed := map[string]time.Time{}
+	cancel := src.OnChange(func(d cache.Diff) {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, c := range d.Modified {
+			refreshed[c.New] = d.LastRefreshed
+		}
+	})
+	defer cancel()
+
+	if err := s.Source().WaitReady(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	type observed struct {
+		value         int
+		lastRefreshed time.Time
+	}
+	var items []observed
+	deadline := time.Now().Add(100 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		item, err := s.Get("key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, observed{item.Value(), item.LastRefreshed()})
+	}
+	src.Stop()
+
+	lock.Lock()
+	defer lock.Unlock()
+	for _, o := range items {
+		at, ok := refreshed[strconv.Itoa(o.value)]
+		if ok && !at.Equal(o.lastRefreshed) {
+			t.Fatal("value", o.value, "refreshed at", at, "returned with", o.lastRefreshed)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Watch returns a channel that receives an Item whenever the value of key
+// changes. A nil Item is sent when the key is removed from the source.
+//
+// Changes are coalesced, if the receiver doesn't keep up only the latest
+// state of the key is delivered. The channel is closed when ctx is done or
+// the source is stopped.
+func (s *source) Watch(ctx context.Context, key string) <-chan Item {
+	ch := make(chan Item, 1)
+
+	if s.ctx.Err() != nil {
+		close(ch)
+		return ch
+	}
+
+	unlisten := s.listen(func(d Diff) {
+		var item Item
+
+		if v, ok := d.Added[key]; ok {
+			item = s.watchItem(v, d.LastRefreshed)
+		} else if c, ok := d.Modified[key]; ok {
+			item = s.watchItem(c.New, d.LastRefreshed)
+		} else if _, ok := d.Removed[key]; !ok {
+			return
+		}
+
+		// Replace undelivered item with the latest one. Diffs are published
+		// only from the refresh goroutine so the send can't block.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- item
+	})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.ctx.Done():
+		}
+
+		// Waits for in-progress publish so nothing is sent after close
+		unlisten()
+		close(ch)
+	}()
+
+	return ch
+}
+
+func (s *source) watchItem(value string, lastRefresh time.Time) Item {
+	return NewItem(value, lastRefresh, s.nextRefreshAfter(lastRefresh))
+}
@@ -0,0 +1,122 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mhrabovcin/cache/pkg/cache"
+)
+
+func TestWatch(t *testing.T) {
+	fetchFunc, next := versionedFetchFunc(
+		map[string]string{"key": "1", "other": "1"},
+		map[string]string{"key": "1", "other": "2"},
+		map[string]string{"key": "2", "other": "2"},
+		map[string]string{"other": "2"},
+	)
+
+	s := cache.NewSource(
+		"test",
+		cache.WithDefaultData(map[string]string{"key": "1", "other": "1"}),
+		cache.WithFetchFunc(fetchFunc, 5*time.Millisecond),
+	)
+	defer s.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := s.Watch(ctx, "key")
+
+	// Change of other key isn't delivered
+	next()
+	select {
+	case item := <-ch:
+		t.Fatal("change of other key shouldn't be delivered:", item)
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	next()
+	select {
+	case item := <-ch:
+		if item == nil || item.Value() != "2" {
+			t.Fatal("changed value should be delivered")
+		}
+		if !item.NextRefresh().Equal(item.LastRefreshed().Add(5 * time.Millisecond)) {
+			t.Fatal("item should include refresh metadata")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("change should be delivered")
+	}
+
+	next()
+	select {
+	case item := <-ch:
+		if item != nil {
+			t.Fatal("removed key should be delivered as nil item")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("removal should be delivered")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("no more items expected")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel should be closed when context is cancelled")
+	}
+}
+
+func TestWatchCoalescesChanges(t *testing.T) {
+	fetchFunc, next := versionedFetchFunc(
+		map[string]string{"key": "1"},
+		map[string]string{"key": "2"},
+		map[string]string{"key": "3"},
+	)
+
+	s := cache.NewSource(
+		"test",
+		cache.WithDefaultData(map[string]string{"key": "1"}),
+		cache.WithFetchFunc(fetchFunc, 5*time.Millisecond),
+	)
+	defer s.Stop()
+
+	ch := s.Watch(context.Background(), "key")
+
+	next()
+	<-time.After(30 * time.Millisecond)
+	next()
+	<-time.After(30 * time.Millisecond)
+
+	item := <-ch
+	if item.Value() != "3" {
+		t.Fatal("only latest value should be delivered, got:", item.Value())
+	}
+
+	select {
+	case item := <-ch:
+		t.Fatal("intermediate value shouldn't be delivered:", item.Value())
+	default:
+	}
+}
+
+func TestWatchClosedOnStop(t *testing.T) {
+	s := cache.NewSource("test")
+	ch := s.Watch(context.Background(), "key")
+	s.Stop()
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("no items expected")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel should be closed on stop")
+	}
+
+	if _, ok := <-s.Watch(context.Background(), "key"); ok {
+		t.Fatal("watching stopped source should return closed channel")
+	}
+}